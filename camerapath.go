@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// cameraKey places the camera at time t, looking from from to to
+// with a field of view of fov radians
+type cameraKey struct {
+	t        float32
+	from, to m.Vector
+	fov      float32
+}
+
+type interpolation int
+
+const (
+	// catmullRom passes through every key, leaving each one in the
+	// direction from the key before it to the key after it, at the speed
+	// it takes to get from one to the other in the time between them
+	catmullRom interpolation = iota
+	// bezier passes through the first and last key only; the keys in
+	// between pull the camera towards them like bezier control points
+	bezier
+)
+
+// cameraPath moves the camera through keys sorted by time. A closed path
+// returns to its first key after the last one, which for catmullRom
+// also smooths the turn through the first key
+type cameraPath struct {
+	keys          []cameraKey
+	interpolation interpolation
+	closed        bool
+}
+
+// duration is the time from the first key to the end of the path
+func (p cameraPath) duration() float32 {
+	if len(p.keys) == 0 {
+		return 0
+	}
+	end := p.keys[len(p.keys)-1].t
+	if p.closed {
+		end += p.closingTime()
+	}
+	return end - p.keys[0].t
+}
+
+// closingTime is the time taken to return to the first key: the average
+// time between keys
+func (p cameraPath) closingTime() float32 {
+	n := len(p.keys)
+	if n < 2 {
+		return 1
+	}
+	return (p.keys[n-1].t - p.keys[0].t) / float32(n-1)
+}
+
+// frameTime returns the time of frame i out of n, spread evenly over the
+// path. Closed paths leave out the end, which is the same as the start
+func (p cameraPath) frameTime(i, n int) float32 {
+	if p.closed {
+		return p.duration() * float32(i) / float32(n)
+	}
+	if n < 2 {
+		return 0
+	}
+	return p.duration() * float32(i) / float32(n-1)
+}
+
+// at returns the camera from, to and field of view at time t,
+// counted from the first key and clamped to the path
+func (p cameraPath) at(t float32) (m.Vector, m.Vector, float32) {
+	keys := p.keys
+	if p.closed {
+		last := keys[0]
+		last.t = keys[len(keys)-1].t + p.closingTime()
+		keys = append(append([]cameraKey{}, keys...), last)
+	}
+	if len(keys) == 1 {
+		return keys[0].from, keys[0].to, keys[0].fov
+	}
+	t = float32(math.Max(0, math.Min(float64(t), float64(p.duration())))) + keys[0].t
+	// each key as a point in 7 dimensions, interpolated as one
+	values := func(k cameraKey) []float32 {
+		return []float32{k.from.X, k.from.Y, k.from.Z, k.to.X, k.to.Y, k.to.Z, k.fov}
+	}
+	var v []float32
+	switch p.interpolation {
+	case bezier:
+		points := make([][]float32, len(keys))
+		for i, k := range keys {
+			points[i] = values(k)
+		}
+		v = deCasteljau(points, (t-keys[0].t)/(keys[len(keys)-1].t-keys[0].t))
+	default:
+		i := 0
+		for i < len(keys)-2 && t >= keys[i+1].t {
+			i++
+		}
+		// neighbours of the segment; open paths repeat their ends and
+		// closed paths take them from the other end, a lap earlier or later
+		prev, next := keys[i], keys[i+1]
+		lap := keys[len(keys)-1].t - keys[0].t
+		switch {
+		case i > 0:
+			prev = keys[i-1]
+		case p.closed:
+			prev = keys[len(keys)-2]
+			prev.t -= lap
+		}
+		switch {
+		case i+2 < len(keys):
+			next = keys[i+2]
+		case p.closed:
+			next = keys[1]
+			next.t += lap
+		}
+		v = catmullRomSpline(values(prev), values(keys[i]), values(keys[i+1]), values(next),
+			[4]float32{prev.t, keys[i].t, keys[i+1].t, next.t}, t)
+	}
+	return m.Vector{v[0], v[1], v[2]}, m.Vector{v[3], v[4], v[5]}, v[6]
+}
+
+// catmullRomSpline returns the point at time t on the segment from p1 to p2
+// of the Catmull-Rom spline through p0, p1, p2 and p3 at times ts.
+// The velocity at p1 is that from p0 to p2 over the time between them,
+// and likewise at p2, so speed carries over smoothly between segments
+// however unevenly the keys are spread in time. Repeating p1 as p0 at the
+// same time, or p2 as p3, makes the spline head straight for the other end
+func catmullRomSpline(p0, p1, p2, p3 []float32, ts [4]float32, t float32) []float32 {
+	d := ts[2] - ts[1]
+	u := (t - ts[1]) / d
+	u2, u3 := u*u, u*u*u
+	// cubic hermite basis, with tangents scaled to the segment
+	h00, h10 := 2*u3-3*u2+1, u3-2*u2+u
+	h01, h11 := -2*u3+3*u2, u3-u2
+	v := make([]float32, len(p1))
+	for i := range v {
+		m1 := (p2[i] - p0[i]) / (ts[2] - ts[0]) * d
+		m2 := (p3[i] - p1[i]) / (ts[3] - ts[1]) * d
+		v[i] = h00*p1[i] + h10*m1 + h01*p2[i] + h11*m2
+	}
+	return v
+}
+
+// deCasteljau returns the point at u in [0,1] on the bezier curve
+// with the given control points
+func deCasteljau(points [][]float32, u float32) []float32 {
+	ps := make([][]float32, len(points))
+	copy(ps, points)
+	for len(ps) > 1 {
+		next := make([][]float32, len(ps)-1)
+		for i := range next {
+			next[i] = make([]float32, len(ps[i]))
+			for j := range next[i] {
+				next[i][j] = (1-u)*ps[i][j] + u*ps[i+1][j]
+			}
+		}
+		ps = next
+	}
+	return ps[0]
+}
+
+// orbitPath circles the camera around to once, at the distance and
+// height of from, in the given time
+func orbitPath(from, to m.Vector, fov, time float32) cameraPath {
+	offset := from.Sub(to)
+	radius := float32(math.Hypot(float64(offset.X), float64(offset.Z)))
+	start := math.Atan2(float64(offset.Z), float64(offset.X))
+	// catmull-rom through 12 points strays less than 0.2% from the circle
+	n := 12
+	keys := make([]cameraKey, n)
+	for i := range keys {
+		angle := start + 2*math.Pi*float64(i)/float64(n)
+		p := m.Vector{radius * float32(math.Cos(angle)), offset.Y, radius * float32(math.Sin(angle))}
+		keys[i] = cameraKey{t: time * float32(i) / float32(n), from: to.Add(p), to: to, fov: fov}
+	}
+	return cameraPath{keys: keys, interpolation: catmullRom, closed: true}
+}
+
+// flythroughPath swoops the camera down from high above and behind from
+// to close in on to, narrowing the field of view, in the given time
+func flythroughPath(from, to m.Vector, fov, time float32) cameraPath {
+	offset := from.Sub(to)
+	high := to.Add(offset.Times(2)).Add(m.Vector{0, offset.Length(), 0})
+	side := to.Add(m.Vector{offset.Z, offset.Y, -offset.X})
+	near := to.Add(offset.Times(0.5))
+	return cameraPath{
+		keys: []cameraKey{
+			{t: 0, from: high, to: to, fov: fov},
+			{t: 0.4 * time, from: side, to: to, fov: fov},
+			{t: 0.7 * time, from: from, to: to, fov: fov},
+			{t: time, from: near, to: to, fov: 0.6 * fov},
+		},
+		interpolation: catmullRom,
+	}
+}
+
+// LoadCameraPath reads keys from a file with a line per key:
+// time, from x y z, to x y z and field of view in degrees
+func LoadCameraPath(filename string, interp interpolation) (cameraPath, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return cameraPath{}, err
+	}
+	defer file.Close()
+
+	keys, err := readCameraKeys(bufio.NewScanner(file))
+	if err != nil {
+		return cameraPath{}, err
+	}
+	return cameraPath{keys: keys, interpolation: interp}, nil
+}
+
+func readCameraKeys(scanner *bufio.Scanner) ([]cameraKey, error) {
+	keys := []cameraKey{}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 8 {
+			return nil, fmt.Errorf("Invalid camera key: %v", fields)
+		}
+		values := make([]float32, len(fields))
+		for i, f := range fields {
+			v, err := strconv.ParseFloat(f, 32)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = float32(v)
+		}
+		key := cameraKey{
+			t:    values[0],
+			from: m.Vector{values[1], values[2], values[3]},
+			to:   m.Vector{values[4], values[5], values[6]},
+			fov:  values[7] * math.Pi / 180.0,
+		}
+		if len(keys) > 0 && key.t <= keys[len(keys)-1].t {
+			return nil, fmt.Errorf("Camera keys out of order at time %f", key.t)
+		}
+		keys = append(keys, key)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("No camera keys")
+	}
+	return keys, nil
+}
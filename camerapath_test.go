@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"math"
+	"strings"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestCameraPathKeys(t *testing.T) {
+	keys := []cameraKey{
+		{t: 0, from: m.Vector{0, 1, -5}, to: m.Vector{0, 0, 0}, fov: 1},
+		{t: 1, from: m.Vector{5, 2, 0}, to: m.Vector{0, 1, 0}, fov: 1.2},
+		{t: 3, from: m.Vector{0, 3, 5}, to: m.Vector{1, 0, 0}, fov: 0.8},
+	}
+	for _, closed := range []bool{false, true} {
+		p := cameraPath{keys: keys, interpolation: catmullRom, closed: closed}
+		// catmull-rom passes through every key
+		for i, k := range keys {
+			from, to, fov := p.at(k.t)
+			if !compareVector(from, k.from) || !compareVector(to, k.to) || !approx(fov, k.fov) {
+				t.Errorf("closed %v: %d): got %v %v %f want %v %v %f", closed, i, from, to, fov, k.from, k.to, k.fov)
+			}
+		}
+	}
+	// a closed path returns to its first key
+	closed := cameraPath{keys: keys, interpolation: catmullRom, closed: true}
+	if d := closed.duration(); !approx(d, 4.5) {
+		t.Errorf("got duration %f want 4.5", d)
+	}
+	if from, _, _ := closed.at(4.5); !compareVector(from, keys[0].from) {
+		t.Errorf("closed path ends at %v", from)
+	}
+
+	// keys spread evenly along a line but unevenly in time
+	// are passed at constant speed, without overshooting
+	line := cameraPath{interpolation: catmullRom}
+	for _, k := range []float32{0, 1, 3, 3.5, 6} {
+		line.keys = append(line.keys, cameraKey{t: k, from: m.Vector{2 * k, 0, 0}, fov: 1})
+	}
+	for i := 0; i <= 60; i++ {
+		tt := float32(i) / 10.0
+		if from, _, _ := line.at(tt); !compareVector(from, m.Vector{2 * tt, 0, 0}) {
+			t.Errorf("line at %f: got %v want %v", tt, from, m.Vector{2 * tt, 0, 0})
+		}
+	}
+
+	// bezier only passes through the ends
+	b := cameraPath{keys: keys, interpolation: bezier}
+	for i, tt := range []struct {
+		t    float32
+		want m.Vector
+	}{
+		{t: 0, want: keys[0].from},
+		{t: 3, want: keys[2].from},
+		{t: 1.5, want: keys[0].from.Times(0.25).Add(keys[1].from.Times(0.5)).Add(keys[2].from.Times(0.25))},
+		// clamped to the path
+		{t: 10, want: keys[2].from},
+	} {
+		if from, _, _ := b.at(tt.t); !compareVector(from, tt.want) {
+			t.Errorf("bezier %d): got %v want %v", i, from, tt.want)
+		}
+	}
+}
+
+func TestOrbitPath(t *testing.T) {
+	from, to := m.Vector{3, 2, -4}, m.Vector{1, 1, 1}
+	p := orbitPath(from, to, 1, 2)
+	if got, _, _ := p.at(0); !compareVector(got, from) {
+		t.Errorf("orbit starts at %v want %v", got, from)
+	}
+	n := 100
+	for i := 0; i < n; i++ {
+		f, target, fov := p.at(p.frameTime(i, n))
+		offset := f.Sub(to)
+		r := math.Hypot(float64(offset.X), float64(offset.Z))
+		if math.Abs(r-math.Hypot(2, 5))/math.Hypot(2, 5) > 0.005 || !approx(offset.Y, 1) {
+			t.Fatalf("%d): camera at %v off the circle", i, f)
+		}
+		if !compareVector(target, to) || !approx(fov, 1) {
+			t.Errorf("%d): got target %v fov %f", i, target, fov)
+		}
+	}
+	if got := p.frameTime(n-1, n); got >= p.duration() {
+		t.Errorf("last frame at %f repeats the first", got)
+	}
+}
+
+func TestReadCameraKeys(t *testing.T) {
+	for i, tt := range []struct {
+		keys    string
+		want    []cameraKey
+		wantErr bool
+	}{
+		{
+			keys: `# time, from, to, fov
+			0 0 1 -5 0 0 0 90
+
+			2.5 1 1 -4 0 0.5 0 45`,
+			want: []cameraKey{
+				{t: 0, from: m.Vector{0, 1, -5}, to: m.Vector{0, 0, 0}, fov: math.Pi / 2},
+				{t: 2.5, from: m.Vector{1, 1, -4}, to: m.Vector{0, 0.5, 0}, fov: math.Pi / 4},
+			},
+		},
+		{keys: `0 0 1 -5 0 0 0`, wantErr: true},
+		{keys: "1 0 1 -5 0 0 0 90\n0 0 1 -5 0 0 0 90", wantErr: true},
+		{keys: `# nothing`, wantErr: true},
+	} {
+		got, err := readCameraKeys(bufio.NewScanner(strings.NewReader(tt.keys)))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%d): got error %v", i, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%d): got %v want %v", i, got, tt.want)
+			continue
+		}
+		for j, k := range got {
+			w := tt.want[j]
+			if k.t != w.t || !compareVector(k.from, w.from) || !compareVector(k.to, w.to) || !approx(k.fov, w.fov) {
+				t.Errorf("%d): key %d got %v want %v", i, j, k, w)
+			}
+		}
+	}
+}
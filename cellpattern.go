@@ -0,0 +1,166 @@
+package main
+
+import (
+	"image"
+	"math"
+	"math/rand"
+
+	perlin "github.com/aquilax/go-perlin"
+	"github.com/fogleman/poissondisc"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// densityFunc returns a value in [0,1] for each point in the XY plane
+type densityFunc func(x, y float32) float32
+
+// imageDensity stretches img over the box from min to max, with its top
+// row at max.Y; density is the brightness of the image
+func imageDensity(img image.Image, min, max m.Vector) densityFunc {
+	b := img.Bounds()
+	return func(x, y float32) float32 {
+		u := (x - min.X) / (max.X - min.X)
+		v := (max.Y - y) / (max.Y - min.Y)
+		px := b.Min.X + int(u*float32(b.Dx()))
+		py := b.Min.Y + int(v*float32(b.Dy()))
+		px = int(math.Max(float64(b.Min.X), math.Min(float64(px), float64(b.Max.X-1))))
+		py = int(math.Max(float64(b.Min.Y), math.Min(float64(py), float64(b.Max.Y-1))))
+		r, g, bl, _ := img.At(px, py).RGBA()
+		return (0.299*float32(r) + 0.587*float32(g) + 0.114*float32(bl)) / 0xffff
+	}
+}
+
+// noiseDensity returns perlin noise with features of about size,
+// mapped from [-1,1] to [0,1]
+func noiseDensity(size float32, seed int64) densityFunc {
+	// alpha, beta, n iterations, random seed
+	p := perlin.NewPerlin(2, 2, 3, seed)
+	return func(x, y float32) float32 {
+		noise := p.Noise2D(float64(x/size), float64(y/size))
+		return float32(math.Max(0, math.Min(1, (noise+1)/2)))
+	}
+}
+
+// cellPatternParams describe a pattern of cells filling a box in the XY plane
+type cellPatternParams struct {
+	min, max m.Vector
+	// distance between sites before relaxation
+	spacing float64
+	// chance to keep a site, by position: fewer sites means larger cells.
+	// All sites are kept if nil
+	density densityFunc
+	// number of Lloyd relaxation steps, see lloydRelax
+	relax int
+	// weight of sites by position, as a fraction of spacing squared;
+	// see powerCells. Plain voronoi cells if nil
+	weight densityFunc
+	seed   int64
+}
+
+// cellPattern returns the sites of the pattern and their cells, as voronoiCells
+func cellPattern(p cellPatternParams) ([]m.Vector, [][]m.Vector) {
+	rnd := rand.New(rand.NewSource(p.seed))
+	points := poissondisc.Sample(float64(p.min.X), float64(p.min.Y), float64(p.max.X), float64(p.max.Y), p.spacing, 30, rnd)
+	sites := []m.Vector{}
+	for _, pt := range points {
+		x, y := float32(pt.X), float32(pt.Y)
+		if p.density != nil && rnd.Float32() >= p.density(x, y) {
+			continue
+		}
+		sites = append(sites, m.Vector{x, y, 0})
+	}
+	var weights []float32
+	if p.weight != nil {
+		weights = make([]float32, len(sites))
+	}
+	for i := 0; ; i++ {
+		for j, s := range sites {
+			if p.weight != nil {
+				weights[j] = p.weight(s.X, s.Y) * float32(p.spacing*p.spacing)
+			}
+		}
+		cells := powerCells(sites, weights, p.min, p.max)
+		if i == p.relax {
+			return sites, cells
+		}
+		sites = lloydStep(sites, cells, p.density)
+	}
+}
+
+// lloydRelax moves each site to the centroid of its cell a number of times,
+// which evens out the size and shape of the cells. If density is not nil,
+// centroids are weighted by it and cells end up smaller where it is higher
+func lloydRelax(sites []m.Vector, min, max m.Vector, iterations int, density densityFunc) []m.Vector {
+	for i := 0; i < iterations; i++ {
+		sites = lloydStep(sites, voronoiCells(sites, min, max), density)
+	}
+	return sites
+}
+
+// lloydStep moves each site to the centroid of its cell;
+// sites without a cell stay where they are
+func lloydStep(sites []m.Vector, cells [][]m.Vector, density densityFunc) []m.Vector {
+	moved := make([]m.Vector, len(sites))
+	for i, cell := range cells {
+		moved[i] = sites[i]
+		if cell != nil {
+			moved[i] = centroid(cell, density)
+		}
+	}
+	return moved
+}
+
+// centroid of a convex polygon in the XY plane. If density is not nil,
+// the polygon is cut into triangles from its center and each is weighted by
+// its area times the density at its centroid
+func centroid(polygon []m.Vector, density densityFunc) m.Vector {
+	var center m.Vector
+	for _, p := range polygon {
+		center = center.Add(p)
+	}
+	center = center.Times(1.0 / float32(len(polygon)))
+	var sum m.Vector
+	var total float32
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		c := center.Add(p).Add(q).Times(1.0 / 3.0)
+		w := polygonArea([]m.Vector{center, p, q})
+		if density != nil {
+			// keep a little weight everywhere so that empty regions still pull
+			w *= density(c.X, c.Y) + 1e-3
+		}
+		sum = sum.Add(c.Times(w))
+		total += w
+	}
+	if total == 0 {
+		return center
+	}
+	return sum.Times(1.0 / total)
+}
+
+// cellPatterns are presets for the voronoi scene, filling the box from min to max
+var cellPatterns = map[string]func(min, max m.Vector, seed int64) cellPatternParams{
+	// plain voronoi cells of poisson disc sites
+	"uniform": func(min, max m.Vector, seed int64) cellPatternParams {
+		return cellPatternParams{min: min, max: max, spacing: 1.0, seed: seed}
+	},
+	// rounded stones of slightly varying size
+	"cobblestone": func(min, max m.Vector, seed int64) cellPatternParams {
+		return cellPatternParams{min: min, max: max, spacing: 0.8, relax: 4, weight: scaled(noiseDensity(3, seed), 0.3), seed: seed}
+	},
+	// irregular flakes, large and small in patches
+	"mud": func(min, max m.Vector, seed int64) cellPatternParams {
+		return cellPatternParams{min: min, max: max, spacing: 0.5, density: noiseDensity(4, seed), relax: 1, weight: scaled(noiseDensity(1, seed+1), 0.5), seed: seed}
+	},
+	// columns of nearly hexagonal cells
+	"basalt": func(min, max m.Vector, seed int64) cellPatternParams {
+		return cellPatternParams{min: min, max: max, spacing: 0.9, relax: 30, seed: seed}
+	},
+}
+
+// scaled multiplies density by f
+func scaled(density densityFunc, f float32) densityFunc {
+	return func(x, y float32) float32 {
+		return f * density(x, y)
+	}
+}
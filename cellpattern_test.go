@@ -0,0 +1,192 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestPowerCells(t *testing.T) {
+	min, max := m.Vector{0, 0, 0}, m.Vector{4, 2, 0}
+	sites := []m.Vector{{1, 1, 0}, {3, 1, 0}, {2, 100, 0}}
+	// the bisector moves by (w0-w1)/2 / |s1-s0| towards the lighter site
+	cells := powerCells(sites, []float32{2, 0, 0}, min, max)
+	want := [][]m.Vector{
+		{{0, 0, 0}, {2.5, 0, 0}, {2.5, 2, 0}, {0, 2, 0}},
+		{{2.5, 0, 0}, {4, 0, 0}, {4, 2, 0}, {2.5, 2, 0}},
+		nil,
+	}
+	for i, cell := range cells {
+		if !samePolygon(cell, want[i]) {
+			t.Errorf("cell %d: got %v want %v", i, cell, want[i])
+		}
+	}
+
+	// random weights up to the spacing squared still tile the box
+	r := rand.New(rand.NewSource(1))
+	min, max = m.Vector{-5, -5, 0}, m.Vector{5, 5, 0}
+	sites, _ = cellPattern(cellPatternParams{min: min, max: max, spacing: 0.5, seed: 1})
+	weights := make([]float32, len(sites))
+	for i := range weights {
+		weights[i] = 0.25 * r.Float32()
+	}
+	var total float32
+	cells = powerCells(sites, weights, min, max)
+	for i, cell := range cells {
+		if cell == nil {
+			continue
+		}
+		if area := polygonArea(cell); area <= 0 {
+			t.Errorf("cell %d is not counter-clockwise: %v", i, cell)
+		}
+		total += polygonArea(cell)
+	}
+	if !approx(total, 100) {
+		t.Errorf("cells cover an area of %f, want 100", total)
+	}
+
+	// weights well above the spacing squared let cells border sites
+	// that are not Delaunay neighbours, nor neighbours of those
+	for i := range weights {
+		weights[i] = 4 * r.Float32() * r.Float32()
+	}
+	cells = powerCells(sites, weights, min, max)
+	total = 0
+	for _, cell := range cells {
+		total += polygonArea(cell)
+	}
+	if !approx(total/100, 1) {
+		t.Errorf("heavily weighted cells cover an area of %f, want 100", total)
+	}
+	for k := 0; k < 1000; k++ {
+		p := m.Vector{-5 + 10*r.Float32(), -5 + 10*r.Float32(), 0}
+		inside := []int{}
+		for i, cell := range cells {
+			if cell != nil && insidePolygon(cell, p) {
+				inside = append(inside, i)
+			}
+		}
+		if len(inside) != 1 {
+			t.Errorf("point %v is in cells %v, want exactly one", p, inside)
+			continue
+		}
+		// and that one cell is the site with the least power distance
+		best, bestPower := -1, float32(math.Inf(1))
+		for i, s := range sites {
+			if power := p.Sub(s).Dot(p.Sub(s)) - weights[i]; power < bestPower {
+				best, bestPower = i, power
+			}
+		}
+		if inside[0] != best {
+			t.Errorf("point %v is in cell %d, want %d", p, inside[0], best)
+		}
+	}
+}
+
+// insidePolygon tells whether p is strictly inside a convex
+// counter-clockwise polygon in the XY plane
+func insidePolygon(polygon []m.Vector, p m.Vector) bool {
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		if (b.X-a.X)*(p.Y-a.Y)-(b.Y-a.Y)*(p.X-a.X) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLloydRelax(t *testing.T) {
+	min, max := m.Vector{0, 0, 0}, m.Vector{10, 10, 0}
+	r := rand.New(rand.NewSource(1))
+	sites := make([]m.Vector, 100)
+	for i := range sites {
+		sites[i] = m.Vector{10 * r.Float32(), 10 * r.Float32(), 0}
+	}
+	// relaxation evens out the size of the cells
+	before := areaSpread(voronoiCells(sites, min, max))
+	relaxed := lloydRelax(sites, min, max, 20, nil)
+	if after := areaSpread(voronoiCells(relaxed, min, max)); after > before/2 {
+		t.Errorf("expected relaxation to even out cell sizes, spread went from %f to %f", before, after)
+	}
+
+	// cells shrink where density is high: the left half of the box
+	density := func(x, y float32) float32 {
+		if x < 5 {
+			return 1
+		}
+		return 0.1
+	}
+	relaxed = lloydRelax(sites, min, max, 20, density)
+	var areas [2]float32
+	var counts [2]int
+	for i, cell := range voronoiCells(relaxed, min, max) {
+		side := 0
+		if relaxed[i].X >= 5 {
+			side = 1
+		}
+		areas[side] += polygonArea(cell)
+		counts[side]++
+	}
+	left, right := areas[0]/float32(counts[0]), areas[1]/float32(counts[1])
+	if left > 0.8*right {
+		t.Errorf("expected smaller cells in the dense half, got mean areas %f and %f", left, right)
+	}
+}
+
+func TestImageDensity(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	img.SetGray(0, 0, color.Gray{255})
+	img.SetGray(1, 1, color.Gray{51})
+	density := imageDensity(img, m.Vector{0, 0, 0}, m.Vector{4, 4, 0})
+	for i, tt := range []struct {
+		x, y float32
+		want float32
+	}{
+		// the top row of the image is at the top of the box
+		{x: 1, y: 3, want: 1},
+		{x: 3, y: 1, want: 0.2},
+		{x: 1, y: 1, want: 0},
+		{x: 4, y: 0, want: 0.2},
+	} {
+		if got := density(tt.x, tt.y); !approx(got, tt.want) {
+			t.Errorf("%d): got %f want %f", i, got, tt.want)
+		}
+	}
+}
+
+func TestCellPatterns(t *testing.T) {
+	min, max := m.Vector{-5, -5, 0}, m.Vector{5, 5, 0}
+	for name, preset := range cellPatterns {
+		sites, cells := cellPattern(preset(min, max, 1))
+		if len(sites) != len(cells) || len(cells) == 0 {
+			t.Errorf("%s: got %d sites and %d cells", name, len(sites), len(cells))
+			continue
+		}
+		var total float32
+		for _, cell := range cells {
+			total += polygonArea(cell)
+		}
+		if !approx(total/100, 1) {
+			t.Errorf("%s: cells cover an area of %f, want 100", name, total)
+		}
+	}
+}
+
+// areaSpread returns the ratio of the largest to the smallest cell
+func areaSpread(cells [][]m.Vector) float32 {
+	var lo, hi float32 = 1e9, 0
+	for _, cell := range cells {
+		a := polygonArea(cell)
+		if a < lo {
+			lo = a
+		}
+		if a > hi {
+			hi = a
+		}
+	}
+	return hi / lo
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// columnParams describe how cells in the XY plane are raised into columns
+// standing on the XZ plane: a point x, y of a cell ends up at x, z=y.
+type columnParams struct {
+	material m.Material
+	// height of a column by the center of its cell, mapped from [0,1]
+	// to [minHeight, maxHeight]; all columns are maxHeight if nil
+	height               densityFunc
+	minHeight, maxHeight float32
+	// width of the gap between neighbouring columns
+	grout float32
+	// width and height of the slope cut along the top edges of each column.
+	// Narrow cells get a smaller bevel, so that the top never vanishes
+	bevel float32
+}
+
+// extrudeCells raises each cell into a column, see columnParams.
+// Cells that vanish in the grout are left out
+func extrudeCells(cells [][]m.Vector, p columnParams) m.Object {
+	objects := []m.Object{}
+	for _, cell := range cells {
+		if cell == nil {
+			continue
+		}
+		base := insetCell(cell, p.grout/2.0)
+		if base == nil {
+			continue
+		}
+		h := p.maxHeight
+		if p.height != nil {
+			c := centroid(base, nil)
+			h = p.minHeight + (p.maxHeight-p.minHeight)*p.height(c.X, c.Y)
+		}
+		objects = append(objects, column(base, h, p.bevel, p.material))
+	}
+	return m.NewComplexObject(objects)
+}
+
+// column returns a closed prism of height h over a convex counter-clockwise
+// polygon in the XY plane, with its top edges bevelled
+func column(polygon []m.Vector, h, bevel float32, mat m.Material) m.Object {
+	bevel = minf(bevel, 0.9*miterLimit(polygon), 0.5*h)
+	top := miterInset(polygon, bevel)
+	at := func(ps []m.Vector, y float32) []m.Vector {
+		ring := make([]m.Vector, len(ps))
+		for i, p := range ps {
+			ring[i] = m.Vector{p.X, y, p.Y}
+		}
+		return ring
+	}
+	bottomRing := at(polygon, 0)
+	edgeRing := at(polygon, h-bevel)
+	topRing := at(top, h)
+
+	// faces are convex polygons, counter-clockwise as seen from outside.
+	// Counter-clockwise in the XY plane is clockwise seen from above in XZ
+	triangles := face(bottomRing, mat)
+	reversed := make([]m.Vector, len(topRing))
+	for i, p := range topRing {
+		reversed[len(topRing)-1-i] = p
+	}
+	triangles = append(triangles, face(reversed, mat)...)
+	n := len(polygon)
+	for i := 0; i < n; i++ {
+		j := (i + 1) % n
+		triangles = append(triangles, face([]m.Vector{bottomRing[i], edgeRing[i], edgeRing[j], bottomRing[j]}, mat)...)
+		if bevel > 0 {
+			triangles = append(triangles, face([]m.Vector{edgeRing[i], topRing[i], topRing[j], edgeRing[j]}, mat)...)
+		}
+	}
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// face triangulates a convex polygon as a fan; triangle normals follow
+// the right-hand rule, so the polygon faces the side it is counter-clockwise from
+func face(points []m.Vector, mat m.Material) []m.Triangle {
+	triangles := make([]m.Triangle, 0, len(points)-2)
+	for i := 1; i < len(points)-1; i++ {
+		triangles = append(triangles, m.NewTriangle(points[0], points[i], points[i+1], mat))
+	}
+	return triangles
+}
+
+// insetCell shrinks a convex counter-clockwise polygon in the XY plane by
+// moving its edges inwards by d. Edges that shrink to nothing disappear;
+// returns nil if nothing is left
+func insetCell(polygon []m.Vector, d float32) []m.Vector {
+	if d == 0 {
+		return polygon
+	}
+	planes := make([]halfPlane, len(polygon))
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		// outward normal of a counter-clockwise edge
+		normal := m.Vector{q.Y - p.Y, p.X - q.X, 0}.Normalize()
+		planes[i] = halfPlane{normal: normal, d: normal.Dot(p) - d}
+	}
+	return clipCell(polygon, planes)
+}
+
+// miterInset moves the edges of a convex counter-clockwise polygon inwards
+// by d, keeping one corner for each corner of the polygon; d should stay
+// below miterLimit
+func miterInset(polygon []m.Vector, d float32) []m.Vector {
+	inset := make([]m.Vector, len(polygon))
+	for i, p := range polygon {
+		inset[i] = p.Add(miter(polygon, i).Times(d))
+	}
+	return inset
+}
+
+// miter returns the direction corner i of a convex counter-clockwise polygon
+// moves in when its edges move inwards by one
+func miter(polygon []m.Vector, i int) m.Vector {
+	n := len(polygon)
+	prev, p, next := polygon[(i+n-1)%n], polygon[i], polygon[(i+1)%n]
+	n1 := inwardNormal(prev, p)
+	n2 := inwardNormal(p, next)
+	return n1.Add(n2).Times(1.0 / (1.0 + n1.Dot(n2)))
+}
+
+func inwardNormal(p, q m.Vector) m.Vector {
+	return m.Vector{p.Y - q.Y, q.X - p.X, 0}.Normalize()
+}
+
+// miterLimit returns how far the edges of a convex counter-clockwise polygon
+// can move inwards, keeping all corners, before the first edge shrinks to nothing
+func miterLimit(polygon []m.Vector) float32 {
+	n := len(polygon)
+	var limit float32 = 1e9
+	for i, p := range polygon {
+		q := polygon[(i+1)%n]
+		edge := q.Sub(p)
+		length := edge.Length()
+		if length == 0 {
+			return 0
+		}
+		shrink := miter(polygon, i).Sub(miter(polygon, (i+1)%n)).Dot(edge.Times(1.0 / length))
+		if shrink > 0 {
+			limit = minf(limit, length/shrink)
+		}
+	}
+	return limit
+}
+
+func minf(x float32, xs ...float32) float32 {
+	for _, y := range xs {
+		if y < x {
+			x = y
+		}
+	}
+	return x
+}
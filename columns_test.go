@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestInsetCell(t *testing.T) {
+	for i, tt := range []struct {
+		polygon []m.Vector
+		d       float32
+		want    []m.Vector
+	}{
+		{
+			polygon: []m.Vector{{0, 0, 0}, {4, 0, 0}, {4, 2, 0}, {0, 2, 0}},
+			d:       0.5,
+			want:    []m.Vector{{0.5, 0.5, 0}, {3.5, 0.5, 0}, {3.5, 1.5, 0}, {0.5, 1.5, 0}},
+		},
+		{
+			polygon: []m.Vector{{0, 0, 0}, {4, 0, 0}, {4, 2, 0}, {0, 2, 0}},
+			d:       1.5,
+			want:    nil,
+		},
+	} {
+		if got := insetCell(tt.polygon, tt.d); !samePolygon(got, tt.want) {
+			t.Errorf("%d): got %v want %v", i, got, tt.want)
+		}
+	}
+
+	// the short edge of the trapezoid disappears
+	trapezoid := []m.Vector{{0, 0, 0}, {4, 0, 0}, {2.2, 3, 0}, {1.8, 3, 0}}
+	if got := insetCell(trapezoid, 0.8); len(got) != 3 || polygonArea(got) <= 0 {
+		t.Errorf("expected a triangle, got %v", got)
+	}
+}
+
+func TestMiterInset(t *testing.T) {
+	square := []m.Vector{{0, 0, 0}, {2, 0, 0}, {2, 2, 0}, {0, 2, 0}}
+	if got := miterLimit(square); !approx(got, 1) {
+		t.Errorf("got limit %f want 1", got)
+	}
+	want := []m.Vector{{0.25, 0.25, 0}, {1.75, 0.25, 0}, {1.75, 1.75, 0}, {0.25, 1.75, 0}}
+	if got := miterInset(square, 0.25); !samePolygon(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+	// the short edge of the trapezoid vanishes first
+	trapezoid := []m.Vector{{0, 0, 0}, {4, 0, 0}, {2.2, 3, 0}, {1.8, 3, 0}}
+	limit := miterLimit(trapezoid)
+	inset := miterInset(trapezoid, limit)
+	if !compareVector(inset[2], inset[3]) {
+		t.Errorf("expected top corners to meet at the limit %f, got %v", limit, inset)
+	}
+}
+
+func TestExtrudeCells(t *testing.T) {
+	mat := &m.DiffuseMaterial{}
+	cells := [][]m.Vector{
+		{{0, 0, 0}, {2, 0, 0}, {2, 2, 0}, {0, 2, 0}},
+		{{2, 0, 0}, {4, 0, 0}, {4, 2, 0}, {2, 2, 0}},
+		nil,
+	}
+	// the left cell is low, the right one high
+	height := func(x, y float32) float32 {
+		if x < 2 {
+			return 0
+		}
+		return 1
+	}
+	o := extrudeCells(cells, columnParams{material: mat, height: height, minHeight: 1, maxHeight: 3, grout: 0.2, bevel: 0.1})
+	b := o.Bound(m.ScaleUniform(1.0))
+	if !compareVector(b.Pmin, m.Vector{0.1, 0, 0.1}) || !compareVector(b.Pmax, m.Vector{3.9, 3, 1.9}) {
+		t.Errorf("got bounds %v %v", b.Pmin, b.Pmax)
+	}
+	for i, tt := range []struct {
+		x, z float32
+		// height of the surface hit from above, or negative for a miss
+		want float32
+	}{
+		{x: 1, z: 1, want: 1},
+		{x: 3, z: 1, want: 3},
+		// the grout between columns
+		{x: 2, z: 1, want: -1},
+		{x: 1, z: 1.95, want: -1},
+		// halfway across the bevel
+		{x: 3.85, z: 1, want: 2.95},
+	} {
+		ray := m.NewRay(m.Vector{tt.x, 10, tt.z}, m.Vector{0, -1, 0})
+		si, hit := o.Intersect(ray)
+		if tt.want < 0 {
+			if hit {
+				t.Errorf("%d): expected a miss, hit at %v", i, si.Point)
+			}
+			continue
+		}
+		if !hit {
+			t.Errorf("%d): expected a hit", i)
+			continue
+		}
+		if !approx(si.Point.Y, tt.want) {
+			t.Errorf("%d): got height %f want %f", i, si.Point.Y, tt.want)
+		}
+		// columns face outwards
+		if si.GetNormal().Dot(ray.Direction) >= 0 {
+			t.Errorf("%d): surface faces away from the ray", i)
+		}
+	}
+	// and from the side
+	ray := m.NewRay(m.Vector{-5, 0.5, 1}, m.Vector{1, 0, 0})
+	if si, hit := o.Intersect(ray); !hit || !approx(si.Point.X, 0.1) || si.GetNormal().Dot(ray.Direction) >= 0 {
+		t.Errorf("expected to hit the side of the column facing outwards")
+	}
+}
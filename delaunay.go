@@ -0,0 +1,43 @@
+package main
+
+import (
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/fogleman/delaunay"
+)
+
+// triangulate returns the delaunay triangulation of points in the XY plane.
+// There is none for fewer than three distinct points or points on a line.
+// Points repeating another point are left out of the triangles
+func triangulate(points []m.Vector) (*delaunay.Triangulation, error) {
+	ps := make([]delaunay.Point, len(points))
+	for i, p := range points {
+		ps[i] = delaunay.Point{X: float64(p.X), Y: float64(p.Y)}
+	}
+	return delaunay.Triangulate(ps)
+}
+
+func nextHalfedge(e int) int {
+	if e%3 == 2 {
+		return e - 2
+	}
+	return e + 1
+}
+
+// delaunayNeighbours returns for each of n points the points it shares
+// an edge with in the triangulation
+func delaunayNeighbours(d *delaunay.Triangulation, n int) [][]int {
+	neighbours := make([][]int, n)
+	add := func(a, b int) {
+		neighbours[a] = append(neighbours[a], b)
+	}
+	for e, a := range d.Triangles {
+		b := d.Triangles[nextHalfedge(e)]
+		add(a, b)
+		// each point has one halfedge leaving it per triangle,
+		// which misses the hull edge arriving at it
+		if d.Halfedges[e] == -1 {
+			add(b, a)
+		}
+	}
+	return neighbours
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// fragment is the part of a closed mesh nearest to its seed: the mesh
+// clipped by the 3D voronoi cell of the seed, with the cuts capped
+type fragment struct {
+	seed      m.Vector
+	triangles []m.Triangle
+}
+
+// fracture splits the closed mesh of triangles into a fragment per seed,
+// leaving out seeds whose cell misses the mesh and seeds repeating an
+// earlier one. Cut faces get material inside. Fragments of a convex mesh
+// are convex; fragments of other meshes can fall apart in several pieces
+// where a cell cuts through the mesh more than once
+func fracture(triangles []m.Triangle, seeds []m.Vector, inside m.Material) []fragment {
+	fragments := []fragment{}
+	mesh := facesOf(triangles)
+	for i, s := range seeds {
+		// nearest seeds first: they cut the most off
+		others := make([]int, 0, len(seeds)-1)
+		for j := range seeds {
+			if j != i {
+				others = append(others, j)
+			}
+		}
+		sort.Slice(others, func(a, b int) bool {
+			return seeds[others[a]].Sub(s).Length() < seeds[others[b]].Sub(s).Length()
+		})
+		piece := mesh
+		for _, j := range others {
+			t := seeds[j]
+			if t == s {
+				if j < i {
+					piece = nil
+					break
+				}
+				continue
+			}
+			// no point of the piece is closer to t than to s if
+			// the piece lies within half the distance between them
+			if 2*boundingRadius(piece, s) < t.Sub(s).Length() {
+				break
+			}
+			normal := t.Sub(s)
+			mid := s.Add(t).Times(0.5)
+			piece = clipFaces(piece, normal, normal.Dot(mid), inside)
+			if len(piece) == 0 {
+				break
+			}
+		}
+		if len(piece) > 0 {
+			fragments = append(fragments, fragment{seed: s, triangles: triangulateFaces(piece)})
+		}
+	}
+	return fragments
+}
+
+// explode moves each fragment away from center, by distance times how far
+// its seed is from center, and returns them as one object
+func explode(fragments []fragment, center m.Vector, distance float32) m.Object {
+	objects := make([]m.Object, len(fragments))
+	for i, f := range fragments {
+		offset := f.seed.Sub(center).Times(distance)
+		moved := make([]m.Triangle, len(f.triangles))
+		for j, t := range f.triangles {
+			moved[j] = m.NewTriangle(t.P0.Add(offset), t.P1.Add(offset), t.P2.Add(offset), t.Material)
+		}
+		objects[i] = m.NewTriangleComplexObject(moved)
+	}
+	return m.NewComplexObject(objects)
+}
+
+// polyFace is a flat face of a mesh being cut up: outlines counter-clockwise
+// around its normal and holes clockwise. Faces are only triangulated once
+// all cuts are made; cutting triangulated faces again and again would turn
+// them into ever thinner slivers
+type polyFace struct {
+	normal   m.Vector
+	loops    [][]m.Vector
+	material m.Material
+}
+
+func facesOf(triangles []m.Triangle) []polyFace {
+	faces := make([]polyFace, 0, len(triangles))
+	for _, t := range triangles {
+		normal := t.P1.Sub(t.P0).Cross(t.P2.Sub(t.P0))
+		if normal == (m.Vector{}) {
+			continue
+		}
+		faces = append(faces, polyFace{normal: normal, loops: [][]m.Vector{{t.P0, t.P1, t.P2}}, material: t.Material})
+	}
+	return faces
+}
+
+// triangulateFaces turns faces back into triangles; faces left whole
+// return the triangle they came from
+func triangulateFaces(faces []polyFace) []m.Triangle {
+	triangles := []m.Triangle{}
+	for _, f := range faces {
+		if len(f.loops) == 1 && len(f.loops[0]) == 3 {
+			l := f.loops[0]
+			triangles = append(triangles, m.NewTriangle(l[0], l[1], l[2], f.material))
+			continue
+		}
+		triangles = append(triangles, triangulateLoops(f.loops, f.normal, f.material)...)
+	}
+	return triangles
+}
+
+func boundingRadius(faces []polyFace, center m.Vector) float32 {
+	var r float32
+	for _, f := range faces {
+		for _, loop := range f.loops {
+			for _, p := range loop {
+				if d := p.Sub(center).Length(); d > r {
+					r = d
+				}
+			}
+		}
+	}
+	return r
+}
+
+// clipMesh keeps the part of a closed mesh where normal.p < d, and closes
+// the cut with faces of material capMat
+func clipMesh(triangles []m.Triangle, normal m.Vector, d float32, capMat m.Material) []m.Triangle {
+	return triangulateFaces(clipFaces(facesOf(triangles), normal, d, capMat))
+}
+
+// clipFaces keeps the part of a closed mesh of faces where normal.p < d,
+// and closes the cut with a face of material capMat. Points on the plane
+// count as outside, including points that only miss it by rounding. Those
+// would otherwise leave tiny edges where cuts meet, which rounding breaks apart
+func clipFaces(faces []polyFace, normal m.Vector, d float32, capMat m.Material) []polyFace {
+	length := normal.Length()
+	normal, d = normal.Times(1.0/length), d/length
+	dist := func(p m.Vector) float32 {
+		v := normal.Dot(p) - d
+		if v > -1e-5 && v < 1e-5 {
+			return 0
+		}
+		return v
+	}
+	inside := true
+	for _, f := range faces {
+		for _, loop := range f.loops {
+			for _, p := range loop {
+				if dist(p) >= 0 {
+					inside = false
+				}
+			}
+		}
+	}
+	if inside {
+		return faces
+	}
+	// intersect edges the same way from both faces sharing them
+	cut := func(p, q m.Vector) m.Vector {
+		if lessVector(q, p) {
+			p, q = q, p
+		}
+		dp, dq := dist(p), dist(q)
+		if dp == 0 {
+			return p
+		}
+		if dq == 0 {
+			return q
+		}
+		return p.Add(q.Sub(p).Times(dp / (dp - dq)))
+	}
+
+	clipped := []polyFace{}
+	// edges of the cut, running counter-clockwise around normal
+	capEdges := map[m.Vector][]m.Vector{}
+	for _, f := range faces {
+		edges := map[m.Vector][]m.Vector{}
+		// points where the outline leaves the kept side and enters it again
+		type crossing struct {
+			p     m.Vector
+			entry bool
+		}
+		crossings := []crossing{}
+		whole, empty := true, true
+		for _, loop := range f.loops {
+			for i, p := range loop {
+				q := loop[(i+1)%len(loop)]
+				pin, qin := dist(p) < 0, dist(q) < 0
+				switch {
+				case pin && qin:
+					edges[p] = append(edges[p], q)
+					empty = false
+				case pin:
+					x := cut(p, q)
+					edges[p] = append(edges[p], x)
+					crossings = append(crossings, crossing{p: x})
+					whole, empty = false, false
+				case qin:
+					n := cut(p, q)
+					edges[n] = append(edges[n], q)
+					crossings = append(crossings, crossing{p: n, entry: true})
+					whole, empty = false, false
+				default:
+					whole = false
+				}
+			}
+		}
+		if whole {
+			clipped = append(clipped, f)
+			continue
+		}
+		if empty {
+			continue
+		}
+		// the cut runs along the face in direction dir, with the kept part
+		// of the face to its left; pair each exit with the entry after it
+		dir := f.normal.Cross(normal)
+		sort.SliceStable(crossings, func(i, j int) bool {
+			ti, tj := crossings[i].p.Dot(dir), crossings[j].p.Dot(dir)
+			if ti != tj {
+				return ti < tj
+			}
+			return !crossings[i].entry && crossings[j].entry
+		})
+		var exit m.Vector
+		open := false
+		for k := 0; k < len(crossings); k++ {
+			c := crossings[k]
+			// where the outline touches the cut in an open stretch,
+			// the stretch ends and starts again at the same point
+			if open && !c.entry && k+1 < len(crossings) && crossings[k+1].entry && crossings[k+1].p == c.p {
+				crossings[k], crossings[k+1] = crossings[k+1], crossings[k]
+				c = crossings[k]
+			}
+			switch {
+			case !c.entry:
+				exit, open = c.p, true
+			case open:
+				if exit != c.p {
+					edges[exit] = append(edges[exit], c.p)
+					capEdges[c.p] = append(capEdges[c.p], exit)
+				}
+				open = false
+			}
+		}
+		if loops := chainLoops(edges); len(loops) > 0 {
+			clipped = append(clipped, polyFace{normal: f.normal, loops: loops, material: f.material})
+		}
+	}
+	if loops := chainLoops(capEdges); len(loops) > 0 {
+		clipped = append(clipped, polyFace{normal: normal, loops: loops, material: capMat})
+	}
+	return clipped
+}
+
+// chainLoops chains edges into closed loops. Where the mesh has holes,
+// chains run from one hole to another; those are closed straight across
+func chainLoops(edges map[m.Vector][]m.Vector) [][]m.Vector {
+	loops := [][]m.Vector{}
+	incoming := map[m.Vector]int{}
+	for _, qs := range edges {
+		for _, q := range qs {
+			incoming[q]++
+		}
+	}
+	// walk from the ends of open chains first, so that they are not cut up,
+	// and in a fixed order, so that cuts are the same every time
+	starts := make([]m.Vector, 0, len(edges))
+	for p := range edges {
+		starts = append(starts, p)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		pi, pj := starts[i], starts[j]
+		openi, openj := incoming[pi] < len(edges[pi]), incoming[pj] < len(edges[pj])
+		if openi != openj {
+			return openi
+		}
+		return lessVector(pi, pj)
+	})
+	for _, start := range starts {
+		for len(edges[start]) > 0 {
+			loop := []m.Vector{start}
+			p := start
+			for len(edges[p]) > 0 {
+				next := edges[p]
+				q := next[len(next)-1]
+				edges[p] = next[:len(next)-1]
+				if q == start {
+					break
+				}
+				loop = append(loop, q)
+				p = q
+			}
+			if len(loop) >= 3 {
+				loops = append(loops, loop)
+			}
+		}
+	}
+	return loops
+}
+
+// triangulateLoops triangulates closed loops in a plane with the given normal.
+// Loops counter-clockwise around the normal are outlines,
+// clockwise loops are holes in the outline around them
+func triangulateLoops(loops [][]m.Vector, normal m.Vector, mat m.Material) []m.Triangle {
+	u, v := planeBasis(normal)
+	flat := func(loop []m.Vector) []m.Vector {
+		ps := make([]m.Vector, len(loop))
+		for i, p := range loop {
+			ps[i] = m.Vector{p.Dot(u), p.Dot(v), 0}
+		}
+		return ps
+	}
+	type outline struct {
+		loop, flat []m.Vector
+		holes      [][]m.Vector
+	}
+	outlines := []*outline{}
+	holes := [][]m.Vector{}
+	for _, loop := range loops {
+		if polygonArea(flat(loop)) > 0 {
+			outlines = append(outlines, &outline{loop: loop, flat: flat(loop)})
+		} else {
+			holes = append(holes, loop)
+		}
+	}
+	for _, hole := range holes {
+		p := flat(hole)[0]
+		// the smallest outline around the hole
+		var best *outline
+		for _, o := range outlines {
+			if pointInPolygon(p, o.flat) && (best == nil || polygonArea(o.flat) < polygonArea(best.flat)) {
+				best = o
+			}
+		}
+		if best != nil {
+			best.holes = append(best.holes, hole)
+		}
+	}
+
+	triangles := []m.Triangle{}
+	for _, o := range outlines {
+		loop := o.loop
+		for _, hole := range o.holes {
+			loop = bridgeHole(loop, hole, flat)
+		}
+		triangles = append(triangles, earClip(loop, flat(loop), mat)...)
+	}
+	return triangles
+}
+
+// planeBasis returns unit vectors u and v in the plane with the given
+// normal, such that u cross v points along the normal
+func planeBasis(normal m.Vector) (m.Vector, m.Vector) {
+	n := normal.Normalize()
+	a := m.Vector{1, 0, 0}
+	if math.Abs(float64(n.X)) > 0.9 {
+		a = m.Vector{0, 1, 0}
+	}
+	u := a.Sub(n.Times(a.Dot(n))).Normalize()
+	return u, n.Cross(u)
+}
+
+// bridgeHole joins a hole to the outline around it with a pair of edges
+// from a corner of the hole to the nearest corner of the outline it can see
+func bridgeHole(loop, hole []m.Vector, flat func([]m.Vector) []m.Vector) []m.Vector {
+	fl, fh := flat(loop), flat(hole)
+	// start from the corner of the hole furthest along u
+	h := 0
+	for i, p := range fh {
+		if p.X > fh[h].X {
+			h = i
+		}
+	}
+	order := make([]int, len(loop))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return fl[order[a]].Sub(fh[h]).Length() < fl[order[b]].Sub(fh[h]).Length()
+	})
+	crosses := func(a, b m.Vector, polygon []m.Vector) bool {
+		for i, p := range polygon {
+			q := polygon[(i+1)%len(polygon)]
+			if segmentsCross(a, b, p, q) {
+				return true
+			}
+		}
+		return false
+	}
+	o := order[0]
+	for _, i := range order {
+		if !crosses(fl[i], fh[h], fl) && !crosses(fl[i], fh[h], fh) {
+			o = i
+			break
+		}
+	}
+	bridged := make([]m.Vector, 0, len(loop)+len(hole)+2)
+	bridged = append(bridged, loop[:o+1]...)
+	for i := 0; i <= len(hole); i++ {
+		bridged = append(bridged, hole[(h+i)%len(hole)])
+	}
+	bridged = append(bridged, loop[o:]...)
+	return bridged
+}
+
+// segmentsCross tells whether segments ab and pq in the XY plane cross
+// at a point other than their ends
+func segmentsCross(a, b, p, q m.Vector) bool {
+	d1 := orient2(p, q, a)
+	d2 := orient2(p, q, b)
+	d3 := orient2(a, b, p)
+	d4 := orient2(a, b, q)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+func orient2(a, b, c m.Vector) float32 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// pointInPolygon tells whether p lies within polygon in the XY plane
+func pointInPolygon(p m.Vector, polygon []m.Vector) bool {
+	in := false
+	for i, a := range polygon {
+		b := polygon[(i+1)%len(polygon)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)/(b.Y-a.Y)*(b.X-a.X) {
+			in = !in
+		}
+	}
+	return in
+}
+
+// earClip triangulates a simple counter-clockwise polygon, given by its
+// points in 3D and flattened to the XY plane, by cutting off ears
+func earClip(points, flat []m.Vector, mat m.Material) []m.Triangle {
+	idx := make([]int, len(points))
+	for i := range idx {
+		idx[i] = i
+	}
+	// flattening rounds off corners on a straight line in 3D
+	// to a slight turn either way
+	convex := func(a, b, c int) bool {
+		ab, bc := flat[b].Sub(flat[a]), flat[c].Sub(flat[b])
+		return orient2(flat[a], flat[b], flat[c]) > 1e-5*ab.Length()*bc.Length()
+	}
+	add := func(triangles []m.Triangle, a, b, c int) []m.Triangle {
+		if points[a] == points[b] || points[b] == points[c] || points[c] == points[a] {
+			return triangles
+		}
+		return append(triangles, m.NewTriangle(points[a], points[b], points[c], mat))
+	}
+	triangles := []m.Triangle{}
+	for len(idx) > 3 {
+		n := len(idx)
+		ear := -1
+		// corners on a straight line are no ears, and neither are corners
+		// cutting off a triangle with another corner in it or on its edges;
+		// either would leave the edges of triangles next to it split in two
+		for k := 0; k < n && ear == -1; k++ {
+			a, b, c := idx[(k+n-1)%n], idx[k], idx[(k+1)%n]
+			if !convex(a, b, c) {
+				continue
+			}
+			isEar := true
+			for _, i := range idx {
+				if i == a || i == b || i == c || flat[i] == flat[a] || flat[i] == flat[b] || flat[i] == flat[c] {
+					continue
+				}
+				if !convex(b, a, i) && !convex(c, b, i) && !convex(a, c, i) {
+					isEar = false
+					break
+				}
+			}
+			if isEar {
+				ear = k
+			}
+		}
+		if ear == -1 {
+			// only straight corners left, or rounding left no clean ear
+			ear = 0
+		}
+		// keep slivers too: leaving them out would open up the mesh
+		triangles = add(triangles, idx[(ear+n-1)%n], idx[ear], idx[(ear+1)%n])
+		idx = append(idx[:ear], idx[ear+1:]...)
+	}
+	if len(idx) == 3 {
+		triangles = add(triangles, idx[0], idx[1], idx[2])
+	}
+	return triangles
+}
+
+// insideMesh tells whether p lies within the closed mesh of triangles,
+// by counting the triangles a ray from p crosses
+func insideMesh(triangles []m.Triangle, p m.Vector) bool {
+	// a skewed direction, so that the ray misses edges of axis aligned meshes
+	dir := m.Vector{0.5773, 0.5774, 0.5775}
+	in := false
+	for _, t := range triangles {
+		// Möller–Trumbore
+		e1, e2 := t.P1.Sub(t.P0), t.P2.Sub(t.P0)
+		h := dir.Cross(e2)
+		a := e1.Dot(h)
+		if a == 0 {
+			continue
+		}
+		s := p.Sub(t.P0)
+		u := s.Dot(h) / a
+		if u < 0 || u > 1 {
+			continue
+		}
+		q := s.Cross(e1)
+		v := dir.Dot(q) / a
+		if v < 0 || u+v > 1 {
+			continue
+		}
+		if e2.Dot(q)/a > 0 {
+			in = !in
+		}
+	}
+	return in
+}
+
+// lessVector orders vectors by X, then Y, then Z
+func lessVector(p, q m.Vector) bool {
+	if p.X != q.X {
+		return p.X < q.X
+	}
+	if p.Y != q.Y {
+		return p.Y < q.Y
+	}
+	return p.Z < q.Z
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestClipMesh(t *testing.T) {
+	mat := &m.DiffuseMaterial{}
+	capMat := &m.DiffuseMaterial{}
+	cube := m.NewCuboid(m.NewAABB(m.Vector{-1, -1, -1}, m.Vector{1, 1, 1}), mat).Tesselate()
+	for i, tt := range []struct {
+		normal m.Vector
+		d      float32
+		volume float32
+	}{
+		{normal: m.Vector{1, 0, 0}, d: 0, volume: 4},
+		{normal: m.Vector{0, 0, -2}, d: 1, volume: 6},
+		{normal: m.Vector{1, 1, 1}, d: 0, volume: 4},
+		// cuts off a corner
+		{normal: m.Vector{1, 1, 1}, d: 2, volume: 8 - 1.0/6.0},
+		// through the edges of the cube
+		{normal: m.Vector{1, 1, 0}, d: 0, volume: 4},
+		{normal: m.Vector{1, 0, 0}, d: 2, volume: 8},
+		{normal: m.Vector{1, 0, 0}, d: -2, volume: 0},
+	} {
+		got := clipMesh(cube, tt.normal, tt.d, capMat)
+		if v := meshVolume(got); !approx(v, tt.volume) {
+			t.Errorf("%d): got volume %f want %f", i, v, tt.volume)
+		}
+		if err := watertight(got); err != nil {
+			t.Errorf("%d): %v", i, err)
+		}
+		for _, tr := range got {
+			if tr.Material != capMat {
+				continue
+			}
+			n := tr.P1.Sub(tr.P0).Cross(tr.P2.Sub(tr.P0))
+			if n.Dot(tt.normal) <= 0 {
+				t.Errorf("%d): cap faces inwards", i)
+			}
+		}
+	}
+}
+
+func TestClipMeshHole(t *testing.T) {
+	// a square ring, cut across so that the cap is a square with a square hole
+	mat := &m.DiffuseMaterial{}
+	ring := []m.Triangle{}
+	for _, box := range []m.AABB{
+		m.NewAABB(m.Vector{-2, -2, -2}, m.Vector{2, -1, 2}),
+		m.NewAABB(m.Vector{-2, 1, -2}, m.Vector{2, 2, 2}),
+	} {
+		ring = append(ring, m.NewCuboid(box, mat).Tesselate()...)
+	}
+	// the two boxes are separate meshes, so this tests two outlines
+	got := clipMesh(ring, m.Vector{0, 0, 1}, 0, mat)
+	if v := meshVolume(got); !approx(v, 16) {
+		t.Errorf("got volume %f want 16", v)
+	}
+	if err := watertight(got); err != nil {
+		t.Errorf("ring: %v", err)
+	}
+
+	// a cube with a cube shaped cavity, cut through both
+	hollow := m.NewCuboid(m.NewAABB(m.Vector{-2, -2, -2}, m.Vector{2, 2, 2}), mat).Tesselate()
+	hollow = append(hollow, m.NewCuboid(m.NewAABB(m.Vector{-1, -1, -1}, m.Vector{1, 1, 1}), mat).TesselateInsideOut()...)
+	got = clipMesh(hollow, m.Vector{0, 0, 1}, 0.5, mat)
+	if v := meshVolume(got); !approx(v, 40-6) {
+		t.Errorf("got volume %f want 34", v)
+	}
+	if err := watertight(got); err != nil {
+		t.Errorf("hollow mesh: %v", err)
+	}
+}
+
+func TestFracture(t *testing.T) {
+	mat := &m.DiffuseMaterial{}
+	cube := m.NewCuboid(m.NewAABB(m.Vector{-1, -1, -1}, m.Vector{1, 1, 1}), mat).Tesselate()
+
+	halves := fracture(cube, []m.Vector{{-0.5, 0, 0}, {0.5, 0, 0}, {0.5, 0, 0}, {5, 0, 0}}, mat)
+	if len(halves) != 2 {
+		t.Fatalf("got %d fragments want 2", len(halves))
+	}
+	for i, f := range halves {
+		if v := meshVolume(f.triangles); !approx(v, 4) {
+			t.Errorf("%d): got volume %f want 4", i, v)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	seeds := make([]m.Vector, 40)
+	for i := range seeds {
+		seeds[i] = m.Vector{2*rnd.Float32() - 1, 2*rnd.Float32() - 1, 2*rnd.Float32() - 1}
+	}
+	fragments := fracture(cube, seeds, mat)
+	if len(fragments) != len(seeds) {
+		t.Fatalf("got %d fragments want %d", len(fragments), len(seeds))
+	}
+	var total float32
+	for i, f := range fragments {
+		v := meshVolume(f.triangles)
+		if v <= 0 {
+			t.Errorf("%d): got volume %f", i, v)
+		}
+		total += v
+		if err := watertight(f.triangles); err != nil {
+			t.Errorf("%d): fragment: %v", i, err)
+		}
+		// each fragment holds the points nearest to its seed
+		for _, tr := range f.triangles {
+			for _, p := range []m.Vector{tr.P0, tr.P1, tr.P2} {
+				d := p.Sub(f.seed).Length()
+				for _, s := range seeds {
+					if s.Sub(p).Length() < d-1e-4 {
+						t.Fatalf("%d): point %v closer to %v than to its seed %v", i, p, s, f.seed)
+					}
+				}
+			}
+		}
+	}
+	if math.Abs(float64(total-8)) > 1e-3 {
+		t.Errorf("got total volume %f want 8", total)
+	}
+}
+
+func TestInsideMesh(t *testing.T) {
+	cube := m.NewCuboid(m.NewAABB(m.Vector{-1, -1, -1}, m.Vector{1, 1, 1}), &m.DiffuseMaterial{}).Tesselate()
+	for i, tt := range []struct {
+		p    m.Vector
+		want bool
+	}{
+		{p: m.Vector{0, 0, 0}, want: true},
+		{p: m.Vector{0.9, -0.9, 0.5}, want: true},
+		{p: m.Vector{2, 0, 0}, want: false},
+		{p: m.Vector{-2, -2, -2}, want: false},
+	} {
+		if got := insideMesh(cube, tt.p); got != tt.want {
+			t.Errorf("%d): got %v want %v", i, got, tt.want)
+		}
+	}
+}
+
+// meshVolume returns the volume enclosed by a closed mesh facing outwards
+func meshVolume(triangles []m.Triangle) float32 {
+	var volume float32
+	for _, t := range triangles {
+		volume += t.P0.Dot(t.P1.Cross(t.P2))
+	}
+	return volume / 6.0
+}
+
+// watertight returns an error unless the mesh is closed and consistently
+// oriented: each directed edge is used exactly once, and so is its reverse.
+// Faces are counterclockwise seen from outside, as .obj expects, so the
+// signed volume of a mesh that is not empty is positive
+func watertight(triangles []m.Triangle) error {
+	type edge struct{ from, to m.Vector }
+	edges := map[edge]int{}
+	for _, tr := range triangles {
+		edges[edge{tr.P0, tr.P1}]++
+		edges[edge{tr.P1, tr.P2}]++
+		edges[edge{tr.P2, tr.P0}]++
+	}
+	if v := meshVolume(triangles); len(triangles) > 0 && v <= 0 {
+		return fmt.Errorf("expected positive signed volume, got %f", v)
+	}
+	for e, n := range edges {
+		if n != 1 || edges[edge{e.to, e.from}] != 1 {
+			return fmt.Errorf("edge %v used %d times, reverse %d times", e, n, edges[edge{e.to, e.from}])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"image"
+	"math"
+	"math/rand"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// stained glass fills the openings of tracery with flat panes halfway through
+// its depth: one for each main light of the innermost sub arches and one for each
+// foil of every rosette. Panes reach halfway under the surrounding bars, which
+// hides their edges. The spandrels between sub arches and rosettes are left open.
+// GRayT has no transmissive materials, so panes are radiant and double-sided:
+// add them to scene.Emitters as well to have them light an interior.
+
+// glassColoring picks the colour of each pane
+type glassColoring interface {
+	// u and v are the coordinates of the centroid of the pane
+	// within the bounding box of the window, both in [0,1]
+	paneColor(i int, u, v float32) m.Color
+}
+
+// paletteColoring picks a random colour from palette for each pane,
+// or white if the palette is empty
+type paletteColoring struct {
+	palette []m.Color
+	seed    int64
+}
+
+func (p paletteColoring) paneColor(i int, u, v float32) m.Color {
+	if len(p.palette) == 0 {
+		return m.NewColor(255, 255, 255)
+	}
+	random := rand.New(rand.NewSource(p.seed + int64(i)))
+	return p.palette[random.Intn(len(p.palette))]
+}
+
+// imageColoring stretches an image over the window,
+// each pane taking the colour of the pixel at its centroid
+type imageColoring struct {
+	img image.Image
+}
+
+func (c imageColoring) paneColor(i int, u, v float32) m.Color {
+	b := c.img.Bounds()
+	x := b.Min.X + int(u*float32(b.Dx()-1))
+	y := b.Min.Y + int((1-v)*float32(b.Dy()-1))
+	r, g, bl, _ := c.img.At(x, y).RGBA()
+	return m.NewColor(uint8(r>>8), uint8(g>>8), uint8(bl>>8))
+}
+
+type stainedGlassParams struct {
+	coloring glassColoring
+	// panes emit their colour times brightness; 0 means 1
+	brightness float32
+}
+
+// archWindowGlass returns the panes for the window tracery with the same params
+func archWindowGlass(params archWindowTraceryParams, glass stainedGlassParams) ([]m.Triangle, error) {
+	outline, err := archFrame(mainArchParams(params), 0)
+	if err != nil {
+		return nil, err
+	}
+	panes, err := traceryPanes(params)
+	if err != nil {
+		return nil, err
+	}
+	// the sub arches of a horseshoe arch bulge out past the jambs
+	jambs := []halfPlane{{normal: m.Vector{-1, 0, 0}, d: -params.bpL.X}, {normal: m.Vector{1, 0, 0}, d: params.bpR.X}}
+	for i, pane := range panes {
+		panes[i] = clipCell(pane, jambs)
+	}
+	return glassPanes(panes, outline, params.depth/2.0, glass), nil
+}
+
+// roseWindowGlass returns the panes for the rose window with the same params
+func roseWindowGlass(params roseWindowParams, glass stainedGlassParams) ([]m.Triangle, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	panes := [][]m.Vector{}
+	for _, rparams := range roseWindowRosettes(params) {
+		panes = append(panes, rosettePanes(rparams)...)
+	}
+	outline := gen.NewCircle(params.center, params.radius).PointsPhaseRange(0, 2*math.Pi, 4*params.numPoints, ex, ey)
+	return glassPanes(panes, outline, params.depth/2.0, glass), nil
+}
+
+// traceryPanes returns the outlines of all panes in the window, following
+// the same recursion as archWindowTracery
+func traceryPanes(params archWindowTraceryParams) ([][]m.Vector, error) {
+	left, right, rparams, err := traceryLayout(params)
+	if err != nil {
+		return nil, err
+	}
+	panes := rosettePanes(rparams)
+	for _, eparams := range []emptyArchWindowTraceryParams{left, right} {
+		if len(params.subLevels) == 0 {
+			pane, err := archFrame(eparams, eparams.offset/2.0)
+			if err != nil {
+				return nil, err
+			}
+			panes = append(panes, pane)
+			continue
+		}
+		sub, err := traceryPanes(subArchParams(params, eparams))
+		if err != nil {
+			return nil, err
+		}
+		panes = append(panes, sub...)
+	}
+	return panes, nil
+}
+
+// rosettePanes returns a sector of the rosette around each foil,
+// reaching halfway into the circle bar
+func rosettePanes(params rosetteParams) [][]m.Vector {
+	alpha := (2 * math.Pi) / float64(params.numFoils)
+	circle := gen.NewCircle(params.center, params.radius+params.width/2.0)
+	panes := make([][]m.Vector, params.numFoils)
+	for n := 0; n < params.numFoils; n++ {
+		beta := params.foilDirection(n)
+		arc := circle.PointsPhaseRange(beta-alpha/2.0, beta+alpha/2.0, params.numPoints, ex, ey)
+		panes[n] = append([]m.Vector{params.center}, arc...)
+	}
+	return panes
+}
+
+// glassPanes triangulates pane outlines at depth z, with colours
+// chosen relative to the bounding box of outline. Panes under horseshoe
+// and ogee arches are not convex, so they are cut into ears
+func glassPanes(panes [][]m.Vector, outline []m.Vector, z float32, glass stainedGlassParams) []m.Triangle {
+	bounds := m.NewAABB(outline[0], outline[0])
+	for _, p := range outline[1:] {
+		bounds = bounds.AddPoint(p)
+	}
+	size := m.VectorFromTo(bounds.Pmin, bounds.Pmax)
+	brightness := glass.brightness
+	if brightness == 0 {
+		brightness = 1
+	}
+
+	triangles := []m.Triangle{}
+	for i, pane := range panes {
+		points := dedupPoints(translatePoints(pane, m.Vector{0, 0, z}))
+		if len(points) < 3 {
+			continue
+		}
+		var centroid m.Vector
+		for _, p := range points {
+			centroid = centroid.Add(p)
+		}
+		centroid = centroid.Times(1.0 / float32(len(points)))
+		u := (centroid.X - bounds.Pmin.X) / size.X
+		v := (centroid.Y - bounds.Pmin.Y) / size.Y
+		color := glass.coloring.paneColor(i, u, v).Times(brightness)
+		mat := m.NewRadiantMaterial(m.ConstantTexture{Color: color})
+		if polygonArea(points) < 0 {
+			points = reversePoints(points)
+		}
+		front := earClip(points, points, mat)
+		for _, t := range front {
+			triangles = append(triangles, t, m.NewTriangle(t.P2, t.P1, t.P0, mat))
+		}
+	}
+	return triangles
+}
+
+// dedupPoints removes consecutive points that coincide, including the
+// last and first, which would otherwise make degenerate triangles
+func dedupPoints(points []m.Vector) []m.Vector {
+	const epsilon = 1e-5
+	deduped := []m.Vector{}
+	for _, p := range points {
+		if len(deduped) > 0 && m.VectorFromTo(deduped[len(deduped)-1], p).Length() < epsilon {
+			continue
+		}
+		deduped = append(deduped, p)
+	}
+	for len(deduped) > 1 && m.VectorFromTo(deduped[len(deduped)-1], deduped[0]).Length() < epsilon {
+		deduped = deduped[:len(deduped)-1]
+	}
+	return deduped
+}
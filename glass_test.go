@@ -0,0 +1,212 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestPaletteColoring(t *testing.T) {
+	palette := []m.Color{m.NewColor(200, 0, 0), m.NewColor(0, 200, 0), m.NewColor(0, 0, 200)}
+	p := paletteColoring{palette: palette, seed: 42}
+	for i := 0; i < 10; i++ {
+		c := p.paneColor(i, 0.5, 0.5)
+		if c != p.paneColor(i, 0.1, 0.9) {
+			t.Errorf("%d): colour depends on position", i)
+		}
+		found := false
+		for _, pc := range palette {
+			found = found || c == pc
+		}
+		if !found {
+			t.Errorf("%d): colour %v not in palette", i, c)
+		}
+	}
+	if c := (paletteColoring{}).paneColor(0, 0.5, 0.5); c != m.NewColor(255, 255, 255) {
+		t.Errorf("empty palette: got %v want white", c)
+	}
+}
+
+func TestImageColoring(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.RGBA{0, 255, 0, 255})
+	img.Set(0, 1, color.RGBA{0, 0, 255, 255})
+	img.Set(1, 1, color.RGBA{255, 255, 255, 255})
+	c := imageColoring{img: img}
+	for i, tt := range []struct {
+		u, v float32
+		want m.Color
+	}{
+		// v runs up the window, y down the image
+		{u: 0, v: 1, want: m.NewColor(255, 0, 0)},
+		{u: 1, v: 1, want: m.NewColor(0, 255, 0)},
+		{u: 0, v: 0, want: m.NewColor(0, 0, 255)},
+		{u: 1, v: 0, want: m.NewColor(255, 255, 255)},
+	} {
+		if got := c.paneColor(i, tt.u, tt.v); got != tt.want {
+			t.Errorf("%d): got %v want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestGlassPanes(t *testing.T) {
+	square := []m.Vector{{0, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, 0, 0}}
+	// a pane that collapses to a line is left out
+	line := []m.Vector{{0, 0, 0}, {1, 0, 0}, {1, 0, 0}, {0, 0, 0}}
+	white := paletteColoring{palette: []m.Color{m.NewColor(100, 100, 100)}}
+	for i, tt := range []struct {
+		brightness float32
+		want       m.Color
+	}{
+		// unset brightness renders panes at their own colour, not black
+		{brightness: 0, want: m.NewColor(100, 100, 100)},
+		{brightness: 2, want: m.NewColor(200, 200, 200)},
+	} {
+		panes := glassPanes([][]m.Vector{square, line}, square, 0.5, stainedGlassParams{coloring: white, brightness: tt.brightness})
+		// each of the two triangles of the square, front and back
+		if len(panes) != 4 {
+			t.Fatalf("%d): expected 4 triangles, got %d", i, len(panes))
+		}
+		var up, down int
+		for _, p := range panes {
+			if !approx(p.P0.Z, 0.5) || !approx(p.P1.Z, 0.5) || !approx(p.P2.Z, 0.5) {
+				t.Errorf("%d): pane %v not at depth 0.5", i, p)
+			}
+			if p.P1.Sub(p.P0).Cross(p.P2.Sub(p.P0)).Z > 0 {
+				up++
+			} else {
+				down++
+			}
+			if c := p.Material.GetColor(nil); c != tt.want {
+				t.Errorf("%d): got colour %v want %v", i, c, tt.want)
+			}
+		}
+		if up != down {
+			t.Errorf("%d): expected panes to be double-sided, got %d facing +z and %d facing -z", i, up, down)
+		}
+	}
+}
+
+func TestGlassPanesNotConvex(t *testing.T) {
+	// a U with its notch cut out of the top, clockwise like the arch frames
+	u := []m.Vector{{0, 3, 0}, {1, 3, 0}, {1, 1, 0}, {2, 1, 0}, {2, 3, 0}, {3, 3, 0}, {3, 0, 0}, {0, 0, 0}}
+	panes := glassPanes([][]m.Vector{u}, u, 0, stainedGlassParams{coloring: paletteColoring{}})
+	var area float32
+	for _, p := range panes {
+		c := p.P0.Add(p.P1).Add(p.P2).Times(1.0 / 3.0)
+		if !insideOutline(u, c) {
+			t.Errorf("triangle %v %v %v fills the notch", p.P0, p.P1, p.P2)
+		}
+		area += float32(math.Abs(float64(polygonArea([]m.Vector{p.P0, p.P1, p.P2}))))
+	}
+	// front and back
+	if !approx(area, 2*7) {
+		t.Errorf("got area %f want %f", area, float32(2*7))
+	}
+}
+
+func TestArchWindowGlass(t *testing.T) {
+	params := archWindowTraceryParams{
+		material:       &m.DiffuseMaterial{},
+		excess:         1.0,
+		outerWidth:     0.1,
+		innerWidth:     0.06,
+		verticalOffset: 0.4,
+		depth:          0.1,
+		pL:             m.Vector{-2, 3, 0},
+		pR:             m.Vector{2, 3, 0},
+		bpL:            m.Vector{-2, 0, 0},
+		bpR:            m.Vector{2, 0, 0},
+		numPoints:      10,
+		numFoils:       3,
+	}
+	for i, tt := range []struct {
+		levels int
+		// a pane per foil of each rosette and per innermost sub arch
+		wantPanes int
+	}{
+		{levels: 0, wantPanes: 3 + 2},
+		{levels: 1, wantPanes: 3 + 2*(4+2)},
+	} {
+		params.subLevels = traceryLevels(tt.levels, 0.04, 0.2, 0.5, 4)
+		panes, err := traceryPanes(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(panes); got != tt.wantPanes {
+			t.Errorf("%d): got %d panes want %d", i, got, tt.wantPanes)
+		}
+		glass, err := archWindowGlass(params, stainedGlassParams{coloring: paletteColoring{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range glass {
+			for _, v := range []m.Vector{p.P0, p.P1, p.P2} {
+				if v.X < -2 || v.X > 2 || v.Y < 0 || v.Y > 3+2*float32(1.7321) || !approx(v.Z, 0.05) {
+					t.Errorf("%d): pane vertex %v outside of the window", i, v)
+				}
+			}
+		}
+	}
+}
+
+func TestArchWindowGlassProfiles(t *testing.T) {
+	params := archWindowTraceryParams{
+		material:       &m.DiffuseMaterial{},
+		outerWidth:     0.1,
+		innerWidth:     0.05,
+		verticalOffset: 0.4,
+		depth:          0.1,
+		pL:             m.Vector{-2, 3, 0},
+		pR:             m.Vector{2, 3, 0},
+		bpL:            m.Vector{-2, 0, 0},
+		bpR:            m.Vector{2, 0, 0},
+		numPoints:      20,
+		numFoils:       3,
+		subLevels:      traceryLevels(1, 0.04, 0.2, 0.5, 4),
+	}
+	for i, profile := range []archProfile{
+		pointedArch{excess: 1.0},
+		segmentalArch{rise: 0.25},
+		horseshoeArch{excess: 0.6},
+		fourCentredArch{smallRadius: 1.0 / 6.0, largeRadius: 2.0 / 3.0, angle: math.Pi / 3.0},
+		ogeeArch{angle: math.Pi / 3.0},
+	} {
+		params.profile = profile
+		outline, err := archFrame(mainArchParams(params), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		panes, err := archWindowGlass(params, stainedGlassParams{coloring: paletteColoring{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// every triangle lies within the opening, up to its edges
+		for _, p := range panes {
+			c := p.P0.Add(p.P1).Add(p.P2).Times(1.0 / 3.0)
+			for _, v := range []m.Vector{c, c.Add(p.P0.Sub(c).Times(0.9)), c.Add(p.P1.Sub(c).Times(0.9)), c.Add(p.P2.Sub(c).Times(0.9))} {
+				if !insideOutline(outline, v) {
+					t.Errorf("%d): pane triangle %v %v %v sticks out of the opening", i, p.P0, p.P1, p.P2)
+					break
+				}
+			}
+		}
+	}
+}
+
+// insideOutline tells whether p lies within a polygon in the XY plane,
+// by counting the edges a ray from p along the x-axis crosses
+func insideOutline(outline []m.Vector, p m.Vector) bool {
+	in := false
+	for i, a := range outline {
+		b := outline[(i+1)%len(outline)]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < a.X+(p.Y-a.Y)/(b.Y-a.Y)*(b.X-a.X) {
+			in = !in
+		}
+	}
+	return in
+}
@@ -6,7 +6,7 @@ require (
 	github.com/aquilax/go-perlin v1.0.0
 	github.com/deosjr/GRayT v0.0.0-20210110141815-3af9ee26d249
 	github.com/deosjr/GenGeo v0.0.0-20200828192307-283ab39052e7
+	github.com/fogleman/delaunay v0.0.0-20180910191513-63f09b4c883d
 	github.com/fogleman/poissondisc v0.0.0-20190923201222-9b82984c50c5
 	github.com/icza/mjpeg v0.0.0-20201020132628-7c1e1838a393 // indirect
-	github.com/pzsz/voronoi v0.0.0-20130609164533-4314be88c79f
 )
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"math"
 
 	m "github.com/deosjr/GRayT/src/model"
@@ -52,12 +53,215 @@ func createArch(pL, pR, mL, mR m.Vector, numPoints int) arch {
 	}
 }
 
+// an archProfile describes the shape of an arch springing from pL and pR.
+// It returns the arch offset inwards by offset, which is used for the inner
+// outline of tracery; with offset 0 left[0] is pL and the last point of right is pR.
+// The number of points returned must not depend on offset.
+// validate returns an error if the arch cannot be built over a span of width w
+// offset inwards by offset; arch assumes it can.
+type archProfile interface {
+	arch(pL, pR m.Vector, offset float32, numPoints int) arch
+	validate(w, offset float32) error
+}
+
+// profileArch returns the arch of profile, or the pointed arch of excess if
+// profile is nil, or an error if it cannot be built
+func profileArch(profile archProfile, excess float32, pL, pR m.Vector, offset float32, numPoints int) (arch, error) {
+	if profile == nil {
+		profile = pointedArch{excess: excess}
+	}
+	if err := profile.validate(m.VectorFromTo(pL, pR).Length(), offset); err != nil {
+		return arch{}, err
+	}
+	return profile.arch(pL, pR, offset, numPoints), nil
+}
+
+// validateOffset checks that offsetting inwards leaves an opening
+func validateOffset(w, offset float32) error {
+	if offset < 0 || offset >= w/2.0 {
+		return fmt.Errorf("arch offset %f not between 0 and half the span %f", offset, w)
+	}
+	return nil
+}
+
+// pointedArch is the two-centred arch from the paper, where
+// excess is the ratio r / distance(pL, pR), excess >= 0.5
+// excess = 0.5 gives a rounded arch, excess = 1 an equilateral arch
+// and excess > 1 a lancet arch
+type pointedArch struct {
+	excess float32
+}
+
+func (p pointedArch) arch(pL, pR m.Vector, offset float32, numPoints int) arch {
+	mL := pL.Add(m.VectorFromTo(pL, pR).Times(p.excess))
+	mR := pR.Add(m.VectorFromTo(pR, pL).Times(p.excess))
+	dir := m.VectorFromTo(pL, pR).Normalize().Times(offset)
+	return createArch(pL.Add(dir), pR.Sub(dir), mL, mR, numPoints)
+}
+
+func (p pointedArch) validate(w, offset float32) error {
+	if p.excess < 0.5 {
+		return fmt.Errorf("pointed arch excess %f below 0.5", p.excess)
+	}
+	return validateOffset(w, offset)
+}
+
+// segmentalArch is a single arc of less than a semicircle,
+// where rise is the ratio height / distance(pL, pR), 0 < rise <= 0.5
+type segmentalArch struct {
+	rise float32
+}
+
+func (p segmentalArch) arch(pL, pR m.Vector, offset float32, numPoints int) arch {
+	w := m.VectorFromTo(pL, pR).Length()
+	h := p.rise * w
+	r := (w*w/4.0 + h*h) / (2 * h)
+	pM := pL.Add(m.VectorFromTo(pL, pR).Times(0.5))
+	center := pM.Sub(m.Vector{0, r - h, 0})
+
+	ro := r - offset
+	dx := w/2.0 - offset
+	dy := float32(math.Sqrt(float64(ro*ro - dx*dx)))
+	from := math.Atan2(float64(dy), float64(-dx))
+	left := gen.NewCircle(center, ro).PointsPhaseRange(from, math.Pi/2.0, numPoints, ex, ey)
+	return mirrorArch(left, pM)
+}
+
+func (p segmentalArch) validate(w, offset float32) error {
+	if p.rise <= 0 || p.rise > 0.5 {
+		return fmt.Errorf("segmental arch rise %f not in (0, 0.5]", p.rise)
+	}
+	return validateOffset(w, offset)
+}
+
+// horseshoeArch is a single arc of more than a semicircle, its center raised
+// above the springing line, where excess is the ratio r / distance(pL, pR), excess > 0.5
+type horseshoeArch struct {
+	excess float32
+}
+
+func (p horseshoeArch) arch(pL, pR m.Vector, offset float32, numPoints int) arch {
+	w := m.VectorFromTo(pL, pR).Length()
+	r := p.excess * w
+	pM := pL.Add(m.VectorFromTo(pL, pR).Times(0.5))
+	center := pM.Add(m.Vector{0, float32(math.Sqrt(float64(r*r - w*w/4.0))), 0})
+
+	ro := r - offset
+	dx := w/2.0 - offset
+	dy := float32(math.Sqrt(float64(ro*ro - dx*dx)))
+	from := math.Atan2(float64(-dy), float64(-dx)) + 2*math.Pi
+	left := gen.NewCircle(center, ro).PointsPhaseRange(from, math.Pi/2.0, numPoints, ex, ey)
+	return mirrorArch(left, pM)
+}
+
+func (p horseshoeArch) validate(w, offset float32) error {
+	if p.excess < 0.5 {
+		return fmt.Errorf("horseshoe arch excess %f below 0.5", p.excess)
+	}
+	return validateOffset(w, offset)
+}
+
+// fourCentredArch is the Tudor arch: each side starts with a small arc centered
+// on the springing line, which continues in a larger and flatter arc up to the top
+// smallRadius and largeRadius are ratios r / distance(pL, pR) and angle is the
+// angle covered by the small arc. For the arch to be pointed, we need
+// smallRadius + (largeRadius - smallRadius) * cos(angle) < 0.5
+type fourCentredArch struct {
+	smallRadius float32
+	largeRadius float32
+	angle       float64
+}
+
+func (p fourCentredArch) arch(pL, pR m.Vector, offset float32, numPoints int) arch {
+	w := m.VectorFromTo(pL, pR).Length()
+	r1, r2 := p.smallRadius*w, p.largeRadius*w
+	pM := pL.Add(m.VectorFromTo(pL, pR).Times(0.5))
+	c1 := pL.Add(m.Vector{r1, 0, 0})
+	// the large arc is tangent to the small one where they meet,
+	// so its center lies on the line from that point through c1
+	u := m.Vector{float32(math.Cos(p.angle)), -float32(math.Sin(p.angle)), 0}
+	c2 := c1.Add(u.Times(r2 - r1))
+
+	lower := gen.NewCircle(c1, r1-offset).PointsPhaseRange(math.Pi, math.Pi-p.angle, numPoints, ex, ey)
+	to := math.Acos(float64((pM.X - c2.X) / (r2 - offset)))
+	upper := gen.NewCircle(c2, r2-offset).PointsPhaseRange(math.Pi-p.angle, to, numPoints, ex, ey)
+	return mirrorArch(append(lower, upper...), pM)
+}
+
+func (p fourCentredArch) validate(w, offset float32) error {
+	if p.smallRadius <= 0 || p.largeRadius <= p.smallRadius {
+		return fmt.Errorf("four-centred arch radii %f and %f not 0 < small < large", p.smallRadius, p.largeRadius)
+	}
+	if p.angle <= 0 || p.angle >= math.Pi/2.0 {
+		return fmt.Errorf("four-centred arch angle %f not between 0 and pi/2", p.angle)
+	}
+	// the large arc has to reach the axis of symmetry, from the left of it
+	r1, r2 := p.smallRadius*w, p.largeRadius*w
+	d := w/2.0 - r1 - (r2-r1)*float32(math.Cos(p.angle))
+	if d <= 0 {
+		return fmt.Errorf("four-centred arch is not pointed: small + (large - small) * cos(angle) >= 0.5")
+	}
+	if offset >= r1 || d > r2-offset {
+		return fmt.Errorf("four-centred arch offset %f too large for its arcs", offset)
+	}
+	return validateOffset(w, offset)
+}
+
+// ogeeArch is the S-curved arch: each side starts with a convex arc centered
+// on the springing line covering angle, which continues in a concave arc of the same
+// radius, ending vertically at the top. The radius follows from angle, 0 < angle < pi/2
+type ogeeArch struct {
+	angle float64
+}
+
+func (p ogeeArch) arch(pL, pR m.Vector, offset float32, numPoints int) arch {
+	w := m.VectorFromTo(pL, pR).Length()
+	pM := pL.Add(m.VectorFromTo(pL, pR).Times(0.5))
+	// the concave arc's center is at distance r from the axis of symmetry:
+	// r - 2r*cos(angle) = w/2 - r
+	r := w / float32(4*(1-math.Cos(p.angle)))
+	c1 := pL.Add(m.Vector{r, 0, 0})
+	u := m.Vector{-float32(math.Cos(p.angle)), float32(math.Sin(p.angle)), 0}
+	c2 := c1.Add(u.Times(2 * r))
+
+	convex := gen.NewCircle(c1, r-offset).PointsPhaseRange(math.Pi, math.Pi-p.angle, numPoints, ex, ey)
+	// the concave arc lies outside of the window, so offsetting inwards grows its radius
+	to := -math.Acos(float64(r / (r + offset)))
+	concave := gen.NewCircle(c2, r+offset).PointsPhaseRange(-p.angle, to, numPoints, ex, ey)
+	return mirrorArch(append(convex, concave...), pM)
+}
+
+func (p ogeeArch) validate(w, offset float32) error {
+	if p.angle <= 0 || p.angle >= math.Pi/2.0 {
+		return fmt.Errorf("ogee arch angle %f not between 0 and pi/2", p.angle)
+	}
+	if r := w / float32(4*(1-math.Cos(p.angle))); offset >= r {
+		return fmt.Errorf("ogee arch offset %f not below its radius %f", offset, r)
+	}
+	return validateOffset(w, offset)
+}
+
+// mirrorArch returns the arch with left arc as given, and the right arc
+// its mirror image in the vertical line through pM
+func mirrorArch(left []m.Vector, pM m.Vector) arch {
+	right := make([]m.Vector, len(left))
+	for i, v := range left {
+		right[len(left)-1-i] = m.Vector{2*pM.X - v.X, v.Y, v.Z}
+	}
+	return arch{
+		left:  left,
+		right: right,
+	}
+}
+
 type archWindowWallParams struct {
 	material m.Material
 	// four points from llhc to ulhc, counterclockwise
 	rectOutline m.Quadrilateral
 	// ratio r / distance(pL, pR), excess >= 0.5
 	excess float32
+	// shape of the arch, defaults to pointedArch using excess if nil
+	profile archProfile
 	// padding between outline and window
 	xPadding      float32
 	bottomPadding float32
@@ -70,7 +274,7 @@ type archWindowWallParams struct {
 }
 
 // archWindowWall returns a rectangular wall with an arch window in it
-func archWindowWall(params archWindowWallParams) m.Object {
+func archWindowWall(params archWindowWallParams) (m.Object, error) {
 	// outline of front face, counterclockwise ordered
 	llhc, lrhc, urhc, ulhc := params.rectOutline.P1, params.rectOutline.P2, params.rectOutline.P3, params.rectOutline.P4
 	rect := []m.Vector{llhc, lrhc, urhc, ulhc}
@@ -86,20 +290,20 @@ func archWindowWall(params archWindowWallParams) m.Object {
 	wholeArch := []m.Vector{pR, bpR, bpL, pL}
 
 	// then add the points on the circles of the actual arch
-	mL := pL.Add(m.VectorFromTo(pL, pR).Times(params.excess))
-	mR := pR.Add(m.VectorFromTo(pR, pL).Times(params.excess))
-
-	arch := createArch(pL, pR, mL, mR, params.numPoints)
+	arch, err := profileArch(params.profile, params.excess, pL, pR, 0, params.numPoints)
+	if err != nil {
+		return nil, err
+	}
 	wholeArch = append(wholeArch, arch.left...)
 	wholeArch = append(wholeArch, arch.right...)
 
-	// triangles of front face
+	// triangles of front face, clockwise so that they face -z
 	front := []m.Triangle{}
-	t1, t2 := m.QuadrilateralToTriangles(llhc, lrhc, m.Vector{maxX, params.bottomPadding, 0}, m.Vector{minX, params.bottomPadding, 0}, params.material)
+	t1, t2 := m.QuadrilateralToTriangles(m.Vector{minX, params.bottomPadding, 0}, m.Vector{maxX, params.bottomPadding, 0}, lrhc, llhc, params.material)
 	front = append(front, t1, t2)
-	t1, t2 = m.QuadrilateralToTriangles(m.Vector{minX, params.bottomPadding, 0}, bpL, pL, m.Vector{minX, params.pLpRY, 0}, params.material)
+	t1, t2 = m.QuadrilateralToTriangles(m.Vector{minX, params.pLpRY, 0}, pL, bpL, m.Vector{minX, params.bottomPadding, 0}, params.material)
 	front = append(front, t1, t2)
-	t1, t2 = m.QuadrilateralToTriangles(bpR, m.Vector{maxX, params.bottomPadding, 0}, m.Vector{maxX, params.pLpRY, 0}, pR, params.material)
+	t1, t2 = m.QuadrilateralToTriangles(pR, m.Vector{maxX, params.pLpRY, 0}, m.Vector{maxX, params.bottomPadding, 0}, bpR, params.material)
 	front = append(front, t1, t2)
 
 	// triangles to arch radiating from upper left/right hand corners
@@ -109,7 +313,7 @@ func archWindowWall(params archWindowWallParams) m.Object {
 	lPoints = append(lPoints, topMidpoint)
 	for i, p1 := range lPoints[:len(lPoints)-1] {
 		p2 := lPoints[i+1]
-		t := m.NewTriangle(ulhc, p1, p2, params.material)
+		t := m.NewTriangle(ulhc, p2, p1, params.material)
 		front = append(front, t)
 	}
 
@@ -117,7 +321,7 @@ func archWindowWall(params archWindowWallParams) m.Object {
 	rPoints = append(rPoints, m.Vector{maxX, params.pLpRY, 0})
 	for i, p1 := range rPoints[:len(rPoints)-1] {
 		p2 := rPoints[i+1]
-		t := m.NewTriangle(urhc, p1, p2, params.material)
+		t := m.NewTriangle(urhc, p2, p1, params.material)
 		front = append(front, t)
 	}
 
@@ -127,23 +331,50 @@ func archWindowWall(params archWindowWallParams) m.Object {
 		Inner:    [][]m.Vector{wholeArch},
 		Material: params.material,
 	}
-	return ef.Extrude(m.Vector{0, 0, params.depth})
+	return ef.Extrude(m.Vector{0, 0, params.depth}), nil
 }
 
-func roundedArchWindowWall(params archWindowWallParams) m.Object {
+func roundedArchWindowWall(params archWindowWallParams) (m.Object, error) {
 	params.excess = 0.5
 	return archWindowWall(params)
 }
 
-func equilateralArchWindowWall(params archWindowWallParams) m.Object {
+func equilateralArchWindowWall(params archWindowWallParams) (m.Object, error) {
 	params.excess = 1.0
 	return archWindowWall(params)
 }
 
+func lancetArchWindowWall(params archWindowWallParams) (m.Object, error) {
+	params.profile = pointedArch{excess: 1.5}
+	return archWindowWall(params)
+}
+
+func segmentalArchWindowWall(params archWindowWallParams) (m.Object, error) {
+	params.profile = segmentalArch{rise: 0.25}
+	return archWindowWall(params)
+}
+
+func horseshoeArchWindowWall(params archWindowWallParams) (m.Object, error) {
+	params.profile = horseshoeArch{excess: 0.6}
+	return archWindowWall(params)
+}
+
+func tudorArchWindowWall(params archWindowWallParams) (m.Object, error) {
+	params.profile = fourCentredArch{smallRadius: 1.0 / 6.0, largeRadius: 2.0 / 3.0, angle: math.Pi / 3.0}
+	return archWindowWall(params)
+}
+
+func ogeeArchWindowWall(params archWindowWallParams) (m.Object, error) {
+	params.profile = ogeeArch{angle: math.Pi / 3.0}
+	return archWindowWall(params)
+}
+
 type emptyArchWindowTraceryParams struct {
 	material m.Material
 	// ratio r / distance(pL, pR), excess >= 0.5
 	excess float32
+	// shape of the arch, defaults to pointedArch using excess if nil
+	profile archProfile
 	// width of tracery, extends inwards
 	offset float32
 	// depth of extrusion
@@ -156,29 +387,24 @@ type emptyArchWindowTraceryParams struct {
 	bpR m.Vector
 	// number of points on a quarter circle arc
 	numPoints int
+	// cross-section of the bars, flat if nil
+	molding moldingProfile
 }
 
 // emptyArchWindowTracery returns window tracery object which is a simple outline
-func emptyArchWindowTracery(params emptyArchWindowTraceryParams) m.Object {
-	mL := params.pL.Add(m.VectorFromTo(params.pL, params.pR).Times(params.excess))
-	mR := params.pR.Add(m.VectorFromTo(params.pR, params.pL).Times(params.excess))
-
-	outerArch := createArch(params.pL, params.pR, mL, mR, params.numPoints)
-	outerFrame := append([]m.Vector{params.pR, params.bpR, params.bpL, params.pL}, outerArch.left...)
-	outerFrame = append(outerFrame, outerArch.right...)
-	outerRev := make([]m.Vector, len(outerFrame))
-	for i, v := range outerFrame {
-		outerRev[len(outerFrame)-1-i] = v
+func emptyArchWindowTracery(params emptyArchWindowTraceryParams) (m.Object, error) {
+	outerFrame, err := archFrame(params, 0)
+	if err != nil {
+		return nil, err
+	}
+	outerRev := reversePoints(outerFrame)
+	innerFrame, err := archFrame(params, params.offset)
+	if err != nil {
+		return nil, err
+	}
+	if params.molding != nil {
+		return sweepBar(params.material, outerFrame, innerFrame, params.depth, params.molding, true), nil
 	}
-
-	ipL := m.Vector{params.pL.X + params.offset, params.pL.Y, params.pL.Z}
-	ipR := m.Vector{params.pR.X - params.offset, params.pR.Y, params.pR.Z}
-	innerArch := createArch(ipL, ipR, mL, mR, params.numPoints)
-
-	ibpL := m.Vector{params.bpL.X + params.offset, params.bpL.Y + params.offset, params.bpL.Z}
-	ibpR := m.Vector{params.bpR.X - params.offset, params.bpR.Y + params.offset, params.bpR.Z}
-	innerFrame := append([]m.Vector{ipR, ibpR, ibpL, ipL}, innerArch.left...)
-	innerFrame = append(innerFrame, innerArch.right...)
 
 	front := gen.JoinPoints([][]m.Vector{outerFrame, innerFrame}, params.material)
 
@@ -188,13 +414,30 @@ func emptyArchWindowTracery(params emptyArchWindowTraceryParams) m.Object {
 		Inner:    [][]m.Vector{innerFrame},
 		Material: params.material,
 	}
-	return ef.Extrude(m.Vector{0, 0, params.depth})
+	return ef.Extrude(m.Vector{0, 0, params.depth}), nil
+}
+
+// archFrame returns the outline of the window offset inwards by offset,
+// clockwise starting from the right endpoint of the arch
+func archFrame(params emptyArchWindowTraceryParams, offset float32) ([]m.Vector, error) {
+	arch, err := profileArch(params.profile, params.excess, params.pL, params.pR, offset, params.numPoints)
+	if err != nil {
+		return nil, err
+	}
+	ipL := arch.left[0]
+	ipR := arch.right[len(arch.right)-1]
+	ibpL := m.Vector{params.bpL.X + offset, params.bpL.Y + offset, params.bpL.Z}
+	ibpR := m.Vector{params.bpR.X - offset, params.bpR.Y + offset, params.bpR.Z}
+	frame := append([]m.Vector{ipR, ibpR, ibpL, ipL}, arch.left...)
+	return append(frame, arch.right...), nil
 }
 
 type archWindowTraceryParams struct {
 	material m.Material
 	// ratio r / distance(pL, pR), excess >= 0.5
 	excess float32
+	// shape of the main and all sub arches, defaults to pointedArch using excess if nil
+	profile archProfile
 	// outerWidth is the width of outermost arch
 	// innerWidth is the width of the inner tracery
 	outerWidth float32
@@ -213,13 +456,69 @@ type archWindowTraceryParams struct {
 	numPoints int
 	// number of foils on the rosette
 	numFoils int
+	// each level subdivides the sub arches of the level above it again
+	// into two sub arches and a rosette; nil means sub arches are left empty
+	subLevels []traceryLevel
+	// cross-section of the bars, flat if nil
+	molding moldingProfile
+}
+
+// traceryLevel holds the parameters for one level of recursive subdivision,
+// where the inner width of the level above becomes the outer width
+type traceryLevel struct {
+	innerWidth     float32
+	verticalOffset float32
+	numFoils       int
+}
+
+// traceryLevels returns n levels of subdivision, starting from innerWidth and verticalOffset
+// and multiplying both by ratio at each next level
+func traceryLevels(n int, innerWidth, verticalOffset, ratio float32, numFoils int) []traceryLevel {
+	levels := make([]traceryLevel, n)
+	for i := 0; i < n; i++ {
+		levels[i] = traceryLevel{
+			innerWidth:     innerWidth,
+			verticalOffset: verticalOffset,
+			numFoils:       numFoils,
+		}
+		innerWidth *= ratio
+		verticalOffset *= ratio
+	}
+	return levels
 }
 
 // archWindowTracery returns window tracery object
-func archWindowTracery(params archWindowTraceryParams) m.Object {
-	eparams := emptyArchWindowTraceryParams{
+// as per the paper, recursion is applied to the sub arches using params.subLevels
+func archWindowTracery(params archWindowTraceryParams) (m.Object, error) {
+	mainArch, err := emptyArchWindowTracery(mainArchParams(params))
+	if err != nil {
+		return nil, err
+	}
+	left, right, rparams, err := traceryLayout(params)
+	if err != nil {
+		return nil, err
+	}
+	leftArch, err := subArchTracery(params, left)
+	if err != nil {
+		return nil, err
+	}
+	rightArch, err := subArchTracery(params, right)
+	if err != nil {
+		return nil, err
+	}
+	r, err := rosette(rparams)
+	if err != nil {
+		return nil, err
+	}
+	return m.NewComplexObject([]m.Object{mainArch, leftArch, rightArch, r}), nil
+}
+
+// mainArchParams returns the outline of the whole window
+func mainArchParams(params archWindowTraceryParams) emptyArchWindowTraceryParams {
+	return emptyArchWindowTraceryParams{
 		material:  params.material,
 		excess:    params.excess,
+		profile:   params.profile,
 		offset:    params.outerWidth,
 		depth:     params.depth,
 		pL:        params.pL,
@@ -227,9 +526,14 @@ func archWindowTracery(params archWindowTraceryParams) m.Object {
 		bpL:       params.bpL,
 		bpR:       params.bpR,
 		numPoints: params.numPoints,
+		molding:   params.molding,
 	}
-	mainArch := emptyArchWindowTracery(eparams)
+}
 
+// traceryLayout returns the left and right sub arches of a window
+// and the rosette in between them
+func traceryLayout(params archWindowTraceryParams) (emptyArchWindowTraceryParams, emptyArchWindowTraceryParams, rosetteParams, error) {
+	eparams := mainArchParams(params)
 	eparams.offset = params.innerWidth
 	innerOffset := m.Vector{params.outerWidth - params.innerWidth, 0, 0}
 	innerWidth := m.Vector{params.innerWidth, 0, 0}
@@ -238,26 +542,36 @@ func archWindowTracery(params archWindowTraceryParams) m.Object {
 	bpM := params.bpL.Add(m.VectorFromTo(params.bpL, params.bpR).Times(0.5))
 
 	// left sub arch
-	eparams.pL = params.pL.Add(verticalOffset).Add(innerOffset)
-	eparams.bpL = params.bpL.Add(innerOffset)
-	eparams.pR = pM.Add(innerWidth.Times(0.5))
-	eparams.bpR = bpM.Add(innerWidth.Times(0.5))
-	leftArch := emptyArchWindowTracery(eparams)
+	left := eparams
+	left.pL = params.pL.Add(verticalOffset).Add(innerOffset)
+	left.bpL = params.bpL.Add(innerOffset)
+	left.pR = pM.Add(innerWidth.Times(0.5))
+	left.bpR = bpM.Add(innerWidth.Times(0.5))
 
 	// rosette
-	mR := params.pR.Add(m.VectorFromTo(params.pR, params.pL).Times(params.excess))
-	rR := m.VectorFromTo(mR, params.pR).Length() - params.outerWidth
-	mLR := eparams.pR.Add(m.VectorFromTo(eparams.pR, eparams.pL).Times(params.excess))
-	rLR := m.VectorFromTo(mLR, eparams.pR).Length()
-
-	// intersection of axis of symmetry and ellipse (mR,mLR,rR+rLR)
-	center := mR.Add(m.VectorFromTo(mR, mLR).Times(0.5))
-	a := (rR + rLR) / 2.0
-	c := m.VectorFromTo(mR, mLR).Length() * 0.5
-	b := float32(math.Sqrt(float64(a*a - c*c)))
-	y := (a/b)*float32(math.Sqrt(float64(b*b)-math.Pow(float64(pM.X-center.X), 2))) + center.Y
-	mC := m.Vector{pM.X, y, 0}
-	r := rR - m.VectorFromTo(mC, mR).Length()
+	var mC m.Vector
+	var r float32
+	if excess, ok := pointedExcess(params); ok {
+		mR := params.pR.Add(m.VectorFromTo(params.pR, params.pL).Times(excess))
+		rR := m.VectorFromTo(mR, params.pR).Length() - params.outerWidth
+		mLR := left.pR.Add(m.VectorFromTo(left.pR, left.pL).Times(excess))
+		rLR := m.VectorFromTo(mLR, left.pR).Length()
+
+		// intersection of axis of symmetry and ellipse (mR,mLR,rR+rLR)
+		center := mR.Add(m.VectorFromTo(mR, mLR).Times(0.5))
+		a := (rR + rLR) / 2.0
+		c := m.VectorFromTo(mR, mLR).Length() * 0.5
+		b := float32(math.Sqrt(float64(a*a - c*c)))
+		y := (a/b)*float32(math.Sqrt(float64(b*b)-math.Pow(float64(pM.X-center.X), 2))) + center.Y
+		mC = m.Vector{pM.X, y, 0}
+		r = rR - m.VectorFromTo(mC, mR).Length()
+	} else {
+		var err error
+		mC, r, err = fitRosette(params, left, pM.X)
+		if err != nil {
+			return left, emptyArchWindowTraceryParams{}, rosetteParams{}, err
+		}
+	}
 	rparams := rosetteParams{
 		material:  params.material,
 		center:    mC,
@@ -266,17 +580,118 @@ func archWindowTracery(params archWindowTraceryParams) m.Object {
 		depth:     params.depth,
 		numPoints: params.numPoints,
 		numFoils:  params.numFoils,
+		molding:   params.molding,
 	}
-	rosette := rosette(rparams)
 
 	// right sub arch
-	eparams.pL = pM.Sub(innerWidth.Times(0.5))
-	eparams.bpL = bpM.Sub(innerWidth.Times(0.5))
-	eparams.pR = params.pR.Add(verticalOffset).Sub(innerOffset)
-	eparams.bpR = params.bpR.Sub(innerOffset)
-	rightArch := emptyArchWindowTracery(eparams)
+	right := eparams
+	right.pL = pM.Sub(innerWidth.Times(0.5))
+	right.bpL = bpM.Sub(innerWidth.Times(0.5))
+	right.pR = params.pR.Add(verticalOffset).Sub(innerOffset)
+	right.bpR = params.bpR.Sub(innerOffset)
+	return left, right, rparams, nil
+}
 
-	return m.NewComplexObject([]m.Object{mainArch, leftArch, rightArch, rosette})
+// pointedExcess returns the excess of the arches if they are pointed arches
+func pointedExcess(params archWindowTraceryParams) (float32, bool) {
+	switch p := params.profile.(type) {
+	case nil:
+		return params.excess, true
+	case pointedArch:
+		return p.excess, true
+	}
+	return 0, false
+}
+
+// fitRosette returns center and radius of the largest circle on the axis of the
+// window at x that fits between the inner outline of the main arch and the outer
+// outline of the sub arches, of which left is one: the other is its mirror image.
+// The radius shrinks with height towards the main arch and grows away from the
+// sub arches, so we search for the height where both distances are equal
+func fitRosette(params archWindowTraceryParams, left emptyArchWindowTraceryParams, x float32) (m.Vector, float32, error) {
+	main, err := profileArch(params.profile, params.excess, params.pL, params.pR, params.outerWidth, params.numPoints)
+	if err != nil {
+		return m.Vector{}, 0, err
+	}
+	sub, err := profileArch(left.profile, left.excess, left.pL, left.pR, 0, params.numPoints)
+	if err != nil {
+		return m.Vector{}, 0, err
+	}
+	mainPoints := append(append([]m.Vector{}, main.left...), main.right...)
+	subPoints := append(append([]m.Vector{}, sub.left...), sub.right...)
+	radius := func(y float32) float32 {
+		c := m.Vector{x, y, 0}
+		return float32(math.Min(float64(polylineDistance(c, mainPoints)), float64(polylineDistance(c, subPoints))))
+	}
+	low, high := maxY(subPoints), maxY(mainPoints)
+	for i := 0; i < 50; i++ {
+		a, b := low+(high-low)/3.0, high-(high-low)/3.0
+		if radius(a) < radius(b) {
+			low = a
+		} else {
+			high = b
+		}
+	}
+	y := (low + high) / 2.0
+	return m.Vector{x, y, 0}, radius(y), nil
+}
+
+// polylineDistance returns the distance from p to the nearest segment of points
+func polylineDistance(p m.Vector, points []m.Vector) float32 {
+	d := float32(math.Inf(1))
+	for i := 0; i < len(points)-1; i++ {
+		a, b := points[i], points[i+1]
+		ab := m.VectorFromTo(a, b)
+		t := float32(0)
+		if l := ab.Dot(ab); l > 0 {
+			t = float32(math.Max(0, math.Min(1, float64(m.VectorFromTo(a, p).Dot(ab)/l))))
+		}
+		if dd := m.VectorFromTo(a.Add(ab.Times(t)), p).Length(); dd < d {
+			d = dd
+		}
+	}
+	return d
+}
+
+func maxY(points []m.Vector) float32 {
+	y := points[0].Y
+	for _, p := range points[1:] {
+		if p.Y > y {
+			y = p.Y
+		}
+	}
+	return y
+}
+
+// subArchTracery returns the tracery for a sub arch described by eparams:
+// an empty arch on the last level, or a subdivided arch otherwise
+func subArchTracery(params archWindowTraceryParams, eparams emptyArchWindowTraceryParams) (m.Object, error) {
+	if len(params.subLevels) == 0 {
+		return emptyArchWindowTracery(eparams)
+	}
+	return archWindowTracery(subArchParams(params, eparams))
+}
+
+// subArchParams returns the next level of subdivision for a sub arch
+func subArchParams(params archWindowTraceryParams, eparams emptyArchWindowTraceryParams) archWindowTraceryParams {
+	level := params.subLevels[0]
+	return archWindowTraceryParams{
+		material:       params.material,
+		excess:         params.excess,
+		profile:        params.profile,
+		outerWidth:     params.innerWidth,
+		innerWidth:     level.innerWidth,
+		verticalOffset: level.verticalOffset,
+		depth:          params.depth,
+		pL:             eparams.pL,
+		pR:             eparams.pR,
+		bpL:            eparams.bpL,
+		bpR:            eparams.bpR,
+		numPoints:      params.numPoints,
+		numFoils:       level.numFoils,
+		subLevels:      params.subLevels[1:],
+		molding:        params.molding,
+	}
 }
 
 type rosetteParams struct {
@@ -287,31 +702,44 @@ type rosetteParams struct {
 	depth     float32
 	numPoints int
 	numFoils  int
-	// TODO lying vs standing
+	// a standing rosette has a foil at the top, a lying rosette a cusp
+	lying bool
+	// extra rotation of all foils around the center, counterclockwise
+	rotation float64
+	// pointed foils are two-centred arches springing from the cusps
+	// instead of round arcs, with their tips touching the outer circle
+	pointed bool
+	// cross-section of the bars, flat if nil
+	molding moldingProfile
 }
 
-func rosette(params rosetteParams) m.Object {
-	innerCircle := gen.NewCircle(params.center, params.radius)
-	ip := innerCircle.PointsPhaseRange(0, 2*math.Pi, params.numPoints, ex, ey)
-	outerCircle := gen.NewCircle(params.center, params.radius+params.width)
-	op := outerCircle.PointsPhaseRange(0, 2*math.Pi, params.numPoints, ex, ey)
-	ipRev := make([]m.Vector, len(ip))
-	for i, v := range ip {
-		ipRev[len(op)-1-i] = v
+// validate checks that the foils fit inside the circle with room for their bars,
+// and that the inner arcs of pointed foils still meet on their axis
+func (params rosetteParams) validate() error {
+	if params.numFoils < 2 {
+		return fmt.Errorf("rosette needs at least 2 foils, got %d", params.numFoils)
 	}
-
-	triangles := gen.JoinPoints([][]m.Vector{ip, op}, params.material)
-	rface := make([]m.Triangle, len(triangles))
-	for i, t := range triangles {
-		rface[len(triangles)-1-i] = t
+	if params.radius <= 0 || params.width <= 0 {
+		return fmt.Errorf("rosette radius %f and width %f not both positive", params.radius, params.width)
 	}
-	ef := gen.ExtrusionFace{
-		Front:    rface,
-		Outer:    [][]m.Vector{op},
-		Inner:    [][]m.Vector{ipRev},
-		Material: params.material,
+	alpha := (2 * math.Pi) / float64(params.numFoils)
+	rR := params.radius + params.width
+	if rF := float32((math.Sin(alpha/2.0) * float64(rR)) / (math.Sin(alpha/2.0) + 1)); rF <= params.width {
+		return fmt.Errorf("rosette foils of radius %f too small for bars of width %f", rF, params.width)
+	} else if params.pointed {
+		_, _, c, rho := pointedFoilArc(alpha, rF)
+		if ri := rho - params.width; ri <= float32(math.Abs(float64(c.X))) {
+			return fmt.Errorf("pointed rosette foils of radius %f too small for bars of width %f", rF, params.width)
+		}
+	}
+	return nil
+}
+
+func rosette(params rosetteParams) (m.Object, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
 	}
-	circle := ef.Extrude(m.Vector{0, 0, params.depth})
+	circle := circleBar(params.material, params.center, params.radius, params.width, params.depth, params.numPoints, params.molding)
 
 	alpha := (2 * math.Pi) / float64(params.numFoils)
 	rR := params.radius + params.width
@@ -320,32 +748,414 @@ func rosette(params rosetteParams) m.Object {
 
 	foils := make([]m.Object, params.numFoils)
 	for n := 0; n < params.numFoils; n++ {
-		// pi/2 determines lying/standing rosette for now
-		beta := (math.Pi / 2) + float64(n)*alpha
-		foilCenter := foilCircle.Point(beta, ex, ey)
-		outerCircle = gen.NewCircle(foilCenter, rF)
-		from := beta - (math.Pi+alpha)/2.0
-		to := beta + (math.Pi+alpha)/2.0
-		op = outerCircle.PointsPhaseRange(from, to, params.numPoints, ex, ey)
-		innerCircle = gen.NewCircle(foilCenter, rF-params.width)
-		ip = innerCircle.PointsPhaseRange(from, to, params.numPoints, ex, ey)
+		beta := params.foilDirection(n)
+		var op, ip []m.Vector
+		if params.pointed {
+			op, ip = pointedFoil(params.center, beta, alpha, rR, rF, params.width, params.numPoints)
+		} else {
+			foilCenter := foilCircle.Point(beta, ex, ey)
+			outerCircle := gen.NewCircle(foilCenter, rF)
+			from := beta - (math.Pi+alpha)/2.0
+			to := beta + (math.Pi+alpha)/2.0
+			op = outerCircle.PointsPhaseRange(from, to, params.numPoints, ex, ey)
+			innerCircle := gen.NewCircle(foilCenter, rF-params.width)
+			ip = innerCircle.PointsPhaseRange(from, to, params.numPoints, ex, ey)
+		}
+		if params.molding != nil {
+			foils[n] = sweepBar(params.material, op, ip, params.depth, params.molding, false)
+			continue
+		}
 		// we join innercircle points to outercircle points list
 		// as they form one outline of the foil form
 		for i := len(ip) - 1; i >= 0; i-- {
 			op = append(op, ip[i])
 		}
 
-		triangles = gen.JoinPointsNonCircular([][]m.Vector{ip, op}, params.material)
-		rface = make([]m.Triangle, len(triangles))
+		triangles := gen.JoinPointsNonCircular([][]m.Vector{ip, op}, params.material)
+		rface := make([]m.Triangle, len(triangles))
 		for i, t := range triangles {
 			rface[len(triangles)-1-i] = t
 		}
-		ef = gen.ExtrusionFace{
+		ef := gen.ExtrusionFace{
 			Front:    rface,
 			Outer:    [][]m.Vector{op},
 			Material: params.material,
 		}
 		foils[n] = ef.Extrude(m.Vector{0, 0, params.depth})
 	}
-	return m.NewComplexObject(append(foils, circle))
+	return m.NewComplexObject(append(foils, circle)), nil
+}
+
+// foilDirection returns the angle from the center of the rosette to its n-th foil
+func (params rosetteParams) foilDirection(n int) float64 {
+	alpha := (2 * math.Pi) / float64(params.numFoils)
+	beta := (math.Pi / 2) + params.rotation + float64(n)*alpha
+	if params.lying {
+		beta += alpha / 2.0
+	}
+	return beta
+}
+
+// pointedFoil returns outer and inner outline of a pointed foil in direction beta
+// in a rosette around center with outer radius rR and foil radius rF.
+// Each half of the foil is an arc from the cusp where the round foil would touch
+// its neighbour to the tip where it would touch the circle, but with a larger
+// radius, its center moved off the foil axis, so that both halves meet in a point.
+func pointedFoil(center m.Vector, beta, alpha float64, rR, rF, width float32, numPoints int) ([]m.Vector, []m.Vector) {
+	// construct the foil pointing up with its center on the origin first
+	cusp, tip, c, rho := pointedFoilArc(alpha, rF)
+
+	from := math.Atan2(float64(cusp.Y-c.Y), float64(cusp.X-c.X))
+	to := math.Atan2(float64(tip.Y-c.Y), float64(tip.X-c.X))
+	if from < to {
+		from += 2 * math.Pi
+	}
+	outer := gen.NewCircle(c, rho).PointsPhaseRange(from, to, numPoints, ex, ey)
+	// inner tip is where the inner arc crosses the axis
+	ri := rho - width
+	innerTipY := c.Y + float32(math.Sqrt(float64(ri*ri-c.X*c.X)))
+	to = math.Atan2(float64(innerTipY-c.Y), float64(-c.X))
+	inner := gen.NewCircle(c, ri).PointsPhaseRange(from, to, numPoints, ex, ey)
+
+	// outlines run counterclockwise from the right cusp, like the round foils
+	outerArch := mirrorArch(outer, m.Vector{})
+	innerArch := mirrorArch(inner, m.Vector{})
+	op := reversePoints(append(outerArch.left, outerArch.right...))
+	ip := reversePoints(append(innerArch.left, innerArch.right...))
+
+	foilCenter := center.Add(m.Vector{0, rR - rF, 0})
+	theta := beta - math.Pi/2.0
+	op = rotatePoints(translatePoints(op, foilCenter), center, theta)
+	ip = rotatePoints(translatePoints(ip, foilCenter), center, theta)
+	return op, ip
+}
+
+// pointedFoilArc returns the cusp and tip of the left half of a pointed foil
+// of radius rF pointing up from the origin, and the center and radius of the
+// arc between them
+func pointedFoilArc(alpha float64, rF float32) (cusp, tip, c m.Vector, rho float32) {
+	cosA, sinA := float32(math.Cos(alpha/2.0)), float32(math.Sin(alpha/2.0))
+	cusp = m.Vector{-rF * cosA, -rF * sinA, 0}
+	tip = m.Vector{0, rF, 0}
+	// the arc center lies on the bisector of cusp and tip, which passes through
+	// the origin: we move it by half of rF to the right side of the axis
+	bisector := m.VectorFromTo(cusp, tip).Cross(m.Vector{0, 0, -1}).Normalize()
+	if bisector.X < 0 {
+		bisector = bisector.Times(-1)
+	}
+	c = bisector.Times(rF / 2.0)
+	return cusp, tip, c, m.VectorFromTo(c, tip).Length()
+}
+
+func reversePoints(points []m.Vector) []m.Vector {
+	rev := make([]m.Vector, len(points))
+	for i, v := range points {
+		rev[len(points)-1-i] = v
+	}
+	return rev
+}
+
+func translatePoints(points []m.Vector, delta m.Vector) []m.Vector {
+	translated := make([]m.Vector, len(points))
+	for i, p := range points {
+		translated[i] = p.Add(delta)
+	}
+	return translated
+}
+
+// rotatePoints rotates points in the XY plane counterclockwise around center
+func rotatePoints(points []m.Vector, center m.Vector, theta float64) []m.Vector {
+	sin, cos := float32(math.Sin(theta)), float32(math.Cos(theta))
+	rotated := make([]m.Vector, len(points))
+	for i, p := range points {
+		d := m.VectorFromTo(center, p)
+		rotated[i] = m.Vector{center.X + d.X*cos - d.Y*sin, center.Y + d.X*sin + d.Y*cos, p.Z}
+	}
+	return rotated
+}
+
+// circleBar returns a circular tracery bar from radius to radius + width
+func circleBar(mat m.Material, center m.Vector, radius, width, depth float32, numPoints int, molding moldingProfile) m.Object {
+	innerCircle := gen.NewCircle(center, radius)
+	ip := innerCircle.PointsPhaseRange(0, 2*math.Pi, numPoints, ex, ey)
+	outerCircle := gen.NewCircle(center, radius+width)
+	op := outerCircle.PointsPhaseRange(0, 2*math.Pi, numPoints, ex, ey)
+	if molding != nil {
+		return sweepBar(mat, op, ip, depth, molding, true)
+	}
+	ipRev := make([]m.Vector, len(ip))
+	for i, v := range ip {
+		ipRev[len(op)-1-i] = v
+	}
+
+	triangles := gen.JoinPoints([][]m.Vector{ip, op}, mat)
+	rface := make([]m.Triangle, len(triangles))
+	for i, t := range triangles {
+		rface[len(triangles)-1-i] = t
+	}
+	ef := gen.ExtrusionFace{
+		Front:    rface,
+		Outer:    [][]m.Vector{op},
+		Inner:    [][]m.Vector{ipRev},
+		Material: mat,
+	}
+	return ef.Extrude(m.Vector{0, 0, depth})
+}
+
+// radialBar returns a straight tracery bar of given width in direction phi
+// from center, between radius r1 and r2
+func radialBar(mat m.Material, center m.Vector, phi float64, r1, r2, width, depth float32, molding moldingProfile) m.Object {
+	dir := m.Vector{float32(math.Cos(phi)), float32(math.Sin(phi)), 0}
+	perp := m.Vector{-dir.Y, dir.X, 0}.Times(width / 2.0)
+	p1 := center.Add(dir.Times(r1))
+	p2 := center.Add(dir.Times(r2))
+	if molding != nil {
+		return sweepBar(mat, []m.Vector{p1.Sub(perp), p2.Sub(perp)}, []m.Vector{p1.Add(perp), p2.Add(perp)}, depth, molding, false)
+	}
+	points := []m.Vector{p1.Sub(perp), p2.Sub(perp), p2.Add(perp), p1.Add(perp)}
+	return gen.ExtrudeSolidFace(points, m.Vector{0, 0, depth}, mat)
+}
+
+type roseWindowParams struct {
+	material m.Material
+	center   m.Vector
+	// outer radius of the window
+	radius float32
+	// width of all tracery bars
+	width     float32
+	depth     float32
+	numPoints int
+	// central rosette
+	hubRadius float32
+	hubFoils  int
+	// spokes radiate out from the hub to the outer circle
+	numSpokes int
+	// rings of rosettes between the spokes, from the hub outwards
+	rings []roseRing
+	// cross-section of the bars, flat if nil
+	molding moldingProfile
+}
+
+// roseRing is a ring of rosettes, one between each pair of spokes, up to outerRadius.
+// Rings are separated by circular bars.
+type roseRing struct {
+	outerRadius float32
+	numFoils    int
+	pointed     bool
+}
+
+// validate checks that the hub, rings and their rosettes fit inside the window
+func (params roseWindowParams) validate() error {
+	frameRadius := params.radius - params.width
+	if params.width <= 0 || params.hubRadius <= 0 || params.hubRadius+params.width >= frameRadius {
+		return fmt.Errorf("rose window hub of radius %f with bars of width %f does not fit in radius %f", params.hubRadius, params.width, params.radius)
+	}
+	if params.numSpokes < 2 {
+		return fmt.Errorf("rose window needs at least 2 spokes, got %d", params.numSpokes)
+	}
+	inner := params.hubRadius + params.width
+	for i, ring := range params.rings {
+		if ring.outerRadius <= inner {
+			return fmt.Errorf("rose window ring %d ends at %f, inside the ring before it at %f", i, ring.outerRadius, inner)
+		}
+		inner = ring.outerRadius + params.width
+	}
+	for i, rparams := range roseWindowRosettes(params) {
+		if err := rparams.validate(); err != nil {
+			return fmt.Errorf("rose window rosette %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// roseWindow returns a circular rose window: a foiled rosette at the center,
+// spokes radiating outwards and concentric rings of smaller rosettes in between
+func roseWindow(params roseWindowParams) (m.Object, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	mat, center, width, depth := params.material, params.center, params.width, params.depth
+	frameRadius := params.radius - width
+	objects := []m.Object{circleBar(mat, center, frameRadius, width, depth, params.numPoints, params.molding)}
+
+	hubOuter := params.hubRadius + width
+	sector := 2 * math.Pi / float64(params.numSpokes)
+	for i := 0; i < params.numSpokes; i++ {
+		phi := math.Pi/2.0 + float64(i)*sector
+		objects = append(objects, radialBar(mat, center, phi, hubOuter, frameRadius, width, depth, params.molding))
+	}
+	for _, ring := range params.rings {
+		if ring.outerRadius < frameRadius {
+			objects = append(objects, circleBar(mat, center, ring.outerRadius, width, depth, params.numPoints, params.molding))
+		}
+	}
+	for _, rparams := range roseWindowRosettes(params) {
+		r, err := rosette(rparams)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, r)
+	}
+	return m.NewComplexObject(objects), nil
+}
+
+// roseWindowRosettes returns the hub of a rose window followed by
+// the rosettes in each of its rings
+func roseWindowRosettes(params roseWindowParams) []rosetteParams {
+	width := params.width
+	frameRadius := params.radius - width
+	rosettes := []rosetteParams{{
+		material:  params.material,
+		center:    params.center,
+		radius:    params.hubRadius,
+		width:     width,
+		depth:     params.depth,
+		numPoints: params.numPoints,
+		numFoils:  params.hubFoils,
+		molding:   params.molding,
+	}}
+
+	sector := 2 * math.Pi / float64(params.numSpokes)
+	inner := params.hubRadius + width
+	for _, ring := range params.rings {
+		outer := ring.outerRadius
+		if outer > frameRadius {
+			outer = frameRadius
+		}
+		// rosettes fit both the ring and the space between two spokes
+		d := (inner + outer) / 2.0
+		r := (outer - inner) / 2.0
+		if fit := d*float32(math.Sin(sector/2.0)) - width/2.0; fit < r {
+			r = fit
+		}
+		for i := 0; i < params.numSpokes; i++ {
+			phi := math.Pi/2.0 + (float64(i)+0.5)*sector
+			c := params.center.Add(m.Vector{float32(math.Cos(phi)), float32(math.Sin(phi)), 0}.Times(d))
+			rosettes = append(rosettes, rosetteParams{
+				material:  params.material,
+				center:    c,
+				radius:    r - width,
+				width:     width,
+				depth:     params.depth,
+				numPoints: params.numPoints,
+				numFoils:  ring.numFoils,
+				rotation:  phi - math.Pi/2.0,
+				pointed:   ring.pointed,
+				molding:   params.molding,
+			})
+		}
+		inner = outer + width
+	}
+	return rosettes
+}
+
+// gothicWindowParams describe an arched window by its size instead of by points.
+// The window is built in its own frame: the opening is centered on x=0 with its sill
+// at y=0, and the front face lies in the XY plane at z=0 facing -z, extruded towards +z.
+// The transform then places it in the world.
+type gothicWindowParams struct {
+	material m.Material
+	// width of the opening and its height from the sill to the top of the arch
+	width  float32
+	height float32
+	// height of the springing line above the sill. The arch is a pointed arch
+	// rising from there to height; if it would be flatter than a rounded arch,
+	// the springing line is lowered to make it a rounded arch
+	springing float32
+	// wall left around the opening on all sides
+	margin float32
+	// depth of the wall
+	depth     float32
+	numPoints int
+	transform m.Transform
+}
+
+// gothicTraceryParams describe the tracery filling a gothicWindow
+type gothicTraceryParams struct {
+	outerWidth     float32
+	innerWidth     float32
+	verticalOffset float32
+	numFoils       int
+	subLevels      []traceryLevel
+	// depth of the tracery, centered in the wall
+	depth float32
+	// cross-section of the bars, flat if nil
+	molding moldingProfile
+}
+
+// arch returns the excess of the arch and its springing line
+func (params gothicWindowParams) arch() (float32, float32) {
+	springing := params.springing
+	if maxSpringing := params.height - params.width/2.0; springing > maxSpringing {
+		springing = maxSpringing
+	}
+	// see createArch for the height of the arch given excess
+	rise := (params.height - springing) / params.width
+	return rise*rise + 0.25, springing
+}
+
+// gothicWindowWall returns a wall with an arch window in it
+func gothicWindowWall(params gothicWindowParams) (m.Object, error) {
+	excess, springing := params.arch()
+	w, mg := params.width/2.0, params.margin
+	wall, err := archWindowWall(archWindowWallParams{
+		material: params.material,
+		rectOutline: m.Quadrilateral{
+			P1: m.Vector{-w - mg, -mg, 0},
+			P2: m.Vector{w + mg, -mg, 0},
+			P3: m.Vector{w + mg, params.height + mg, 0},
+			P4: m.Vector{-w - mg, params.height + mg, 0},
+		},
+		excess:        excess,
+		xPadding:      mg,
+		bottomPadding: 0,
+		depth:         params.depth,
+		pLpRY:         springing,
+		numPoints:     params.numPoints,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transformObject(wall, params.transform), nil
+}
+
+// gothicWindowTracery returns the tracery filling the window opening
+func gothicWindowTracery(params gothicWindowParams, tracery gothicTraceryParams) (m.Object, error) {
+	offset := m.Translate(m.Vector{0, 0, (params.depth - tracery.depth) / 2.0})
+	obj, err := archWindowTracery(params.traceryParams(tracery))
+	if err != nil {
+		return nil, err
+	}
+	return transformObject(obj, params.transform.Mul(offset)), nil
+}
+
+// gothicWindowGlass returns the stained glass panes for the tracery of the window
+func gothicWindowGlass(params gothicWindowParams, tracery gothicTraceryParams, glass stainedGlassParams) ([]m.Triangle, error) {
+	transform := params.transform.Mul(m.Translate(m.Vector{0, 0, (params.depth - tracery.depth) / 2.0}))
+	panes, err := archWindowGlass(params.traceryParams(tracery), glass)
+	if err != nil {
+		return nil, err
+	}
+	return transformTriangles(panes, transform), nil
+}
+
+func (params gothicWindowParams) traceryParams(tracery gothicTraceryParams) archWindowTraceryParams {
+	excess, springing := params.arch()
+	w := params.width / 2.0
+	return archWindowTraceryParams{
+		material:       params.material,
+		excess:         excess,
+		outerWidth:     tracery.outerWidth,
+		innerWidth:     tracery.innerWidth,
+		verticalOffset: tracery.verticalOffset,
+		depth:          tracery.depth,
+		pL:             m.Vector{-w, springing, 0},
+		pR:             m.Vector{w, springing, 0},
+		bpL:            m.Vector{-w, 0, 0},
+		bpR:            m.Vector{w, 0, 0},
+		numPoints:      params.numPoints,
+		numFoils:       tracery.numFoils,
+		subLevels:      tracery.subLevels,
+		molding:        tracery.molding,
+	}
 }
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestGothicWindowWall(t *testing.T) {
+	mat := &m.DiffuseMaterial{}
+	for i, tt := range []struct {
+		transform m.Transform
+		wantMin   m.Vector
+		wantMax   m.Vector
+		// a ray through the opening should not hit the wall,
+		// a ray through the wall should hit its front face
+		opening m.Ray
+		wall    m.Ray
+	}{
+		{
+			transform: m.ScaleUniform(1.0),
+			wantMin:   m.Vector{-1.5, -0.5, 0},
+			wantMax:   m.Vector{1.5, 3.5, 0.2},
+			opening:   m.NewRay(m.Vector{0, 2.5, -5}, ez),
+			wall:      m.NewRay(m.Vector{1.2, 2.5, -5}, ez),
+		},
+		{
+			transform: m.Translate(m.Vector{10, 1, 0}),
+			wantMin:   m.Vector{8.5, 0.5, 0},
+			wantMax:   m.Vector{11.5, 4.5, 0.2},
+			opening:   m.NewRay(m.Vector{10, 3.5, -5}, ez),
+			wall:      m.NewRay(m.Vector{11.2, 3.5, -5}, ez),
+		},
+		{
+			// facing -x, with its front face at x=0
+			transform: m.RotateY(math.Pi / 2.0),
+			wantMin:   m.Vector{-0.2, -0.5, -1.5},
+			wantMax:   m.Vector{0, 3.5, 1.5},
+			opening:   m.NewRay(m.Vector{-5, 2.5, 0}, ex),
+			wall:      m.NewRay(m.Vector{-5, 2.5, 1.2}, ex),
+		},
+	} {
+		wall, err := gothicWindowWall(gothicWindowParams{
+			material:  mat,
+			width:     2,
+			height:    3,
+			springing: 1.5,
+			margin:    0.5,
+			depth:     0.2,
+			numPoints: 10,
+			transform: tt.transform,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := wall.Bound(m.ScaleUniform(1.0))
+		if !compareVector(b.Pmin, tt.wantMin) || !compareVector(b.Pmax, tt.wantMax) {
+			t.Errorf("%d): got bounds %v %v want %v %v", i, b.Pmin, b.Pmax, tt.wantMin, tt.wantMax)
+		}
+		if _, hit := wall.Intersect(tt.opening); hit {
+			t.Errorf("%d): ray through opening hit the wall", i)
+		}
+		si, hit := wall.Intersect(tt.wall)
+		if !hit {
+			t.Errorf("%d): ray through wall missed", i)
+			continue
+		}
+		if si.GetNormal().Dot(tt.wall.Direction) >= 0 {
+			t.Errorf("%d): front face normal %v faces away from ray", i, si.GetNormal())
+		}
+	}
+}
+
+func TestGothicWindowTracery(t *testing.T) {
+	mat := &m.DiffuseMaterial{}
+	for i, tt := range []struct {
+		width, height, springing float32
+		wantHeight               float32
+	}{
+		{width: 2, height: 3, springing: 1.5, wantHeight: 3},
+		{width: 4, height: 8, springing: 4, wantHeight: 8},
+		// springing too high for a pointed arch: lowered to a rounded arch
+		{width: 2, height: 3, springing: 2.5, wantHeight: 3},
+	} {
+		params := gothicWindowParams{
+			material:  mat,
+			width:     tt.width,
+			height:    tt.height,
+			springing: tt.springing,
+			margin:    0.5,
+			depth:     0.3,
+			numPoints: 10,
+			transform: m.ScaleUniform(1.0),
+		}
+		tracery := gothicTraceryParams{
+			outerWidth:     0.1,
+			innerWidth:     0.05,
+			verticalOffset: 0.2,
+			numFoils:       3,
+			depth:          0.1,
+		}
+		obj, err := gothicWindowTracery(params, tracery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := obj.Bound(m.ScaleUniform(1.0))
+		wantMin := m.Vector{-tt.width / 2.0, 0, 0.1}
+		wantMax := m.Vector{tt.width / 2.0, tt.wantHeight, 0.2}
+		if !compareVector(b.Pmin, wantMin) || !compareVector(b.Pmax, wantMax) {
+			t.Errorf("%d): got bounds %v %v want %v %v", i, b.Pmin, b.Pmax, wantMin, wantMax)
+		}
+		ray := m.NewRay(m.Vector{-tt.width/2.0 + 0.05, 0.5, -5}, ez)
+		if si, hit := obj.Intersect(ray); !hit || si.GetNormal().Dot(ray.Direction) >= 0 {
+			t.Errorf("%d): ray through outer bar should hit its front face", i)
+		}
+		glass, err := gothicWindowGlass(params, tracery, stainedGlassParams{coloring: paletteColoring{palette: []m.Color{{}}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range glass {
+			for _, v := range []m.Vector{p.P0, p.P1, p.P2} {
+				if v.X < wantMin.X || v.X > wantMax.X || v.Y < wantMin.Y || v.Y > wantMax.Y || !approx(v.Z, 0.15) {
+					t.Errorf("%d): pane vertex %v outside of opening", i, v)
+				}
+			}
+		}
+	}
+}
+
+func TestTraceryLevels(t *testing.T) {
+	levels := traceryLevels(3, 0.1, 0.4, 0.5, 4)
+	want := []traceryLevel{
+		{innerWidth: 0.1, verticalOffset: 0.4, numFoils: 4},
+		{innerWidth: 0.05, verticalOffset: 0.2, numFoils: 4},
+		{innerWidth: 0.025, verticalOffset: 0.1, numFoils: 4},
+	}
+	if len(levels) != len(want) {
+		t.Fatalf("got %d levels want %d", len(levels), len(want))
+	}
+	for i, l := range levels {
+		if !approx(l.innerWidth, want[i].innerWidth) || !approx(l.verticalOffset, want[i].verticalOffset) || l.numFoils != want[i].numFoils {
+			t.Errorf("%d): got %v want %v", i, l, want[i])
+		}
+	}
+	if len(traceryLevels(0, 0.1, 0.4, 0.5, 4)) != 0 {
+		t.Errorf("expected no levels for n=0")
+	}
+}
+
+func TestSubArchTracery(t *testing.T) {
+	mat := &m.DiffuseMaterial{}
+	params := archWindowTraceryParams{
+		material:       mat,
+		excess:         1.0,
+		outerWidth:     0.1,
+		innerWidth:     0.06,
+		verticalOffset: 0.4,
+		depth:          0.1,
+		pL:             m.Vector{-2, 3, 0},
+		pR:             m.Vector{2, 3, 0},
+		bpL:            m.Vector{-2, 0, 0},
+		bpR:            m.Vector{2, 0, 0},
+		numPoints:      10,
+		numFoils:       3,
+		subLevels:      traceryLevels(2, 0.04, 0.2, 0.5, 4),
+	}
+	left, right, _, err := traceryLayout(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, eparams := range []emptyArchWindowTraceryParams{left, right} {
+		sub := subArchParams(params, eparams)
+		if !approx(sub.outerWidth, params.innerWidth) || !approx(sub.innerWidth, 0.04) || !approx(sub.verticalOffset, 0.2) {
+			t.Errorf("%d): got widths %f %f and offset %f", i, sub.outerWidth, sub.innerWidth, sub.verticalOffset)
+		}
+		if sub.numFoils != 4 || len(sub.subLevels) != 1 {
+			t.Errorf("%d): got %d foils and %d levels left", i, sub.numFoils, len(sub.subLevels))
+		}
+		if sub.pL != eparams.pL || sub.pR != eparams.pR || sub.bpL != eparams.bpL || sub.bpR != eparams.bpR {
+			t.Errorf("%d): sub arch does not span the outline it subdivides", i)
+		}
+
+		// subdividing fills the same outline with more tracery
+		empty, err := emptyArchWindowTracery(eparams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subdivided, err := subArchTracery(params, eparams)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eb, sb := empty.Bound(m.ScaleUniform(1.0)), subdivided.Bound(m.ScaleUniform(1.0))
+		if !compareVector(eb.Pmin, sb.Pmin) || !compareVector(eb.Pmax, sb.Pmax) {
+			t.Errorf("%d): got bounds %v %v want %v %v", i, sb.Pmin, sb.Pmax, eb.Pmin, eb.Pmax)
+		}
+		if len(trianglesFromObject(subdivided)) <= len(trianglesFromObject(empty)) {
+			t.Errorf("%d): expected subdivided arch to have more triangles than an empty one", i)
+		}
+	}
+	last := params
+	last.subLevels = nil
+	lastArch, err := subArchTracery(last, left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := emptyArchWindowTracery(left)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(trianglesFromObject(lastArch)), len(trianglesFromObject(empty)); got != want {
+		t.Errorf("last level: got %d triangles want those of an empty arch, %d", got, want)
+	}
+}
+
+func TestArchProfiles(t *testing.T) {
+	pL, pR := m.Vector{-1, 2, 0}, m.Vector{1, 2, 0}
+	for i, tt := range []struct {
+		profile archProfile
+		offset  float32
+		valid   bool
+		// height of the top of the arch above the springing line at offset 0
+		wantRise float32
+	}{
+		{profile: pointedArch{excess: 0.5}, valid: true, wantRise: 1},
+		{profile: pointedArch{excess: 1.0}, offset: 0.2, valid: true, wantRise: float32(math.Sqrt(3))},
+		{profile: pointedArch{excess: 0.4}},
+		{profile: pointedArch{excess: 1.0}, offset: 1},
+		{profile: pointedArch{excess: 1.0}, offset: -0.1},
+		{profile: segmentalArch{rise: 0.25}, offset: 0.2, valid: true, wantRise: 0.5},
+		{profile: segmentalArch{rise: 0}},
+		{profile: segmentalArch{rise: 0.6}},
+		{profile: horseshoeArch{excess: 0.6}, offset: 0.2, valid: true, wantRise: 1.2 + float32(math.Sqrt(1.44-1))},
+		{profile: horseshoeArch{excess: 0.4}},
+		{profile: fourCentredArch{smallRadius: 1.0 / 6.0, largeRadius: 2.0 / 3.0, angle: math.Pi / 3.0}, offset: 0.2, valid: true},
+		{profile: fourCentredArch{smallRadius: 2.0 / 3.0, largeRadius: 1.0 / 6.0, angle: math.Pi / 3.0}},
+		// not pointed: the large arc never reaches the axis
+		{profile: fourCentredArch{smallRadius: 0.45, largeRadius: 0.6, angle: 0.1}},
+		// offset beyond the small arc
+		{profile: fourCentredArch{smallRadius: 1.0 / 6.0, largeRadius: 2.0 / 3.0, angle: math.Pi / 3.0}, offset: 0.4},
+		{profile: ogeeArch{angle: math.Pi / 3.0}, offset: 0.2, valid: true, wantRise: float32(math.Sqrt(3))},
+		{profile: ogeeArch{angle: math.Pi / 2.0}},
+		{profile: ogeeArch{angle: 1.3}, offset: 0.8},
+	} {
+		err := tt.profile.validate(2, tt.offset)
+		if (err == nil) != tt.valid {
+			t.Errorf("%d): expected valid=%t, got %v", i, tt.valid, err)
+			continue
+		}
+		if !tt.valid {
+			continue
+		}
+		for _, offset := range []float32{0, tt.offset} {
+			a := tt.profile.arch(pL, pR, offset, 10)
+			if len(a.left) != len(a.right) {
+				t.Errorf("%d): arcs of %d and %d points", i, len(a.left), len(a.right))
+			}
+			for j, p := range a.left {
+				q := a.right[len(a.right)-1-j]
+				if math.IsNaN(float64(p.X)) || math.IsNaN(float64(p.Y)) || !compareVector(p, m.Vector{-q.X, q.Y, q.Z}) {
+					t.Errorf("%d): offset %f: left %v does not mirror right %v", i, offset, p, q)
+					break
+				}
+			}
+		}
+		a := tt.profile.arch(pL, pR, 0, 10)
+		if !compareVector(a.left[0], pL) || !compareVector(a.right[len(a.right)-1], pR) {
+			t.Errorf("%d): arch does not spring from pL and pR: %v %v", i, a.left[0], a.right[len(a.right)-1])
+		}
+		if tt.wantRise != 0 && !approx(maxY(a.left)-pL.Y, tt.wantRise) {
+			t.Errorf("%d): got rise %f want %f", i, maxY(a.left)-pL.Y, tt.wantRise)
+		}
+	}
+}
+
+// notPointed hides that it is a pointedArch from traceryLayout
+type notPointed struct {
+	pointedArch
+}
+
+func TestArchWindowTraceryProfile(t *testing.T) {
+	params := archWindowTraceryParams{
+		material:       &m.DiffuseMaterial{},
+		excess:         1.0,
+		outerWidth:     0.1,
+		innerWidth:     0.06,
+		verticalOffset: 0.4,
+		depth:          0.1,
+		pL:             m.Vector{-2, 3, 0},
+		pR:             m.Vector{2, 3, 0},
+		bpL:            m.Vector{-2, 0, 0},
+		bpR:            m.Vector{2, 0, 0},
+		numPoints:      20,
+		numFoils:       3,
+	}
+	// fitting the rosette numerically agrees with the pointed arch construction,
+	// up to the difference between the arcs and the segments between their points
+	_, _, want, err := traceryLayout(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params.profile = notPointed{pointedArch{excess: 1.0}}
+	_, _, got, err := traceryLayout(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(float64(got.center.Y-want.center.Y)) > 0.01 || math.Abs(float64(got.radius-want.radius)) > 0.01 {
+		t.Errorf("got rosette at %v radius %f want %v radius %f", got.center, got.radius, want.center, want.radius)
+	}
+
+	// the tracery takes the shape of the profile
+	for i, profile := range []archProfile{
+		segmentalArch{rise: 0.25},
+		horseshoeArch{excess: 0.6},
+		fourCentredArch{smallRadius: 1.0 / 6.0, largeRadius: 2.0 / 3.0, angle: math.Pi / 3.0},
+		ogeeArch{angle: math.Pi / 3.0},
+	} {
+		params.profile = profile
+		a := profile.arch(params.pL, params.pR, 0, params.numPoints)
+		tracery, err := archWindowTracery(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := tracery.Bound(m.ScaleUniform(1.0))
+		if !approx(b.Pmax.Y, maxY(a.left)) {
+			t.Errorf("%d): got tracery height %f want %f", i, b.Pmax.Y, maxY(a.left))
+		}
+		// the rosette sits between the sub arches and the main arch
+		left, _, rparams, err := traceryLayout(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		main := profile.arch(params.pL, params.pR, params.outerWidth, params.numPoints)
+		sub := profile.arch(left.pL, left.pR, 0, params.numPoints)
+		if rparams.radius <= 0 || rparams.center.Y <= maxY(sub.left) || rparams.center.Y+rparams.radius > maxY(main.left)+1e-3 {
+			t.Errorf("%d): rosette at %v radius %f does not fit", i, rparams.center, rparams.radius)
+		}
+	}
+}
+
+func TestProfileArchErrors(t *testing.T) {
+	if _, err := profileArch(segmentalArch{rise: 0.8}, 0, m.Vector{-1, 0, 0}, m.Vector{1, 0, 0}, 0, 10); err == nil {
+		t.Error("expected error on invalid arch")
+	}
+	// the error surfaces from the tracery built on the arch
+	params := archWindowTraceryParams{
+		material:       &m.DiffuseMaterial{},
+		profile:        segmentalArch{rise: 0.8},
+		outerWidth:     0.1,
+		innerWidth:     0.05,
+		verticalOffset: 0.4,
+		depth:          0.1,
+		pL:             m.Vector{-2, 3, 0},
+		pR:             m.Vector{2, 3, 0},
+		bpL:            m.Vector{-2, 0, 0},
+		bpR:            m.Vector{2, 0, 0},
+		numPoints:      10,
+		numFoils:       3,
+	}
+	if _, err := archWindowTracery(params); err == nil {
+		t.Error("expected error on tracery with an invalid arch")
+	}
+}
+
+func TestRosetteValidate(t *testing.T) {
+	for i, tt := range []struct {
+		params rosetteParams
+		valid  bool
+	}{
+		{params: rosetteParams{radius: 1, width: 0.1, numFoils: 3}, valid: true},
+		{params: rosetteParams{radius: 1, width: 0.1, numFoils: 8}, valid: true},
+		{params: rosetteParams{radius: -0.1, width: 0.1, numFoils: 3}},
+		{params: rosetteParams{radius: 1, width: 0, numFoils: 3}},
+		{params: rosetteParams{radius: 1, width: 0.1, numFoils: 1}},
+		// foils too small to hold their own bars
+		{params: rosetteParams{radius: 1, width: 0.1, numFoils: 40}},
+		// wide bars fit round foils, but the inner arcs of pointed foils
+		// would pass each other before reaching the axis
+		{params: rosetteParams{radius: 1, width: 0.6, numFoils: 3}, valid: true},
+		{params: rosetteParams{radius: 1, width: 0.6, numFoils: 3, pointed: true}},
+		{params: rosetteParams{radius: 1, width: 0.5, numFoils: 3, pointed: true}, valid: true},
+	} {
+		if err := tt.params.validate(); (err == nil) != tt.valid {
+			t.Errorf("%d): expected valid=%t, got %v", i, tt.valid, err)
+		}
+		p := tt.params
+		p.material, p.depth, p.numPoints = &m.DiffuseMaterial{}, 0.1, 10
+		if _, err := rosette(p); (err == nil) != tt.valid {
+			t.Errorf("%d): rosette expected valid=%t, got %v", i, tt.valid, err)
+		}
+		if !tt.valid || !tt.params.pointed {
+			continue
+		}
+		alpha := 2 * math.Pi / float64(tt.params.numFoils)
+		rR := tt.params.radius + tt.params.width
+		rF := float32((math.Sin(alpha/2.0) * float64(rR)) / (math.Sin(alpha/2.0) + 1))
+		op, ip := pointedFoil(m.Vector{}, math.Pi/2.0, alpha, rR, rF, tt.params.width, 10)
+		for _, p := range append(op, ip...) {
+			if math.IsNaN(float64(p.X)) || math.IsNaN(float64(p.Y)) {
+				t.Errorf("%d): pointed foil has NaN points", i)
+				break
+			}
+		}
+	}
+}
+
+func TestRoseWindow(t *testing.T) {
+	params := roseWindowParams{
+		material:  &m.DiffuseMaterial{},
+		center:    m.Vector{0, 5, 0},
+		radius:    4,
+		width:     0.1,
+		depth:     0.2,
+		numPoints: 10,
+		hubRadius: 1,
+		hubFoils:  6,
+		numSpokes: 8,
+		rings:     []roseRing{{outerRadius: 2.5, numFoils: 4}, {outerRadius: 4, numFoils: 3, pointed: true}},
+	}
+	window, err := roseWindow(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the outer circle is a polygon, which can fall a little short of the radius
+	b := window.Bound(m.ScaleUniform(1.0))
+	for _, d := range []float32{b.Pmax.X - 4, -4 - b.Pmin.X, b.Pmax.Y - 9, 1 - b.Pmin.Y} {
+		if d > 1e-3 || d < -0.1 {
+			t.Errorf("got bounds %v %v want about %v %v", b.Pmin, b.Pmax, m.Vector{-4, 1, 0}, m.Vector{4, 9, 0.2})
+			break
+		}
+	}
+	rosettes := roseWindowRosettes(params)
+	if len(rosettes) != 1+2*8 {
+		t.Errorf("expected hub and 8 rosettes per ring, got %d", len(rosettes))
+	}
+	for i, r := range rosettes {
+		if d := m.VectorFromTo(params.center, r.center).Length() + r.radius + r.width; d > params.radius-params.width+1e-3 {
+			t.Errorf("%d): rosette reaches %f, beyond the frame", i, d)
+		}
+	}
+
+	for i, invalid := range []func(*roseWindowParams){
+		func(p *roseWindowParams) { p.hubRadius = 4 },
+		func(p *roseWindowParams) { p.numSpokes = 1 },
+		// rings out of order
+		func(p *roseWindowParams) {
+			p.rings = []roseRing{{outerRadius: 3, numFoils: 4}, {outerRadius: 2, numFoils: 3}}
+		},
+		// a ring too narrow for its rosettes, which would get a negative radius
+		func(p *roseWindowParams) { p.rings = []roseRing{{outerRadius: 1.2, numFoils: 4}} },
+	} {
+		p := params
+		invalid(&p)
+		if _, err := roseWindow(p); err == nil {
+			t.Errorf("%d): expected error", i)
+		}
+	}
+}
+
+func compareVector(u, v m.Vector) bool {
+	return approx(u.X, v.X) && approx(u.Y, v.Y) && approx(u.Z, v.Z)
+}
+
+func approx(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 1e-3
+}
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-    "math"
-    "math/rand"
-
-    //TODO: replace with fogleman delaunay lib
-    "github.com/pzsz/voronoi"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
 
 	m "github.com/deosjr/GRayT/src/model"
 	"github.com/deosjr/GRayT/src/render"
@@ -22,19 +22,36 @@ var (
 	ex = m.Vector{1, 0, 0}
 	ey = m.Vector{0, 1, 0}
 	ez = m.Vector{0, 0, 1}
+
+	sceneName    = flag.String("scene", "voronoi", "scene to render: voronoi, causeway, paving, shatter, pointcloud, morphospace, shell, nave or zonemortalis")
+	pattern      = flag.String("pattern", "uniform", "cell pattern of the voronoi scene: uniform, cobblestone, mud or basalt")
+	pigment      = flag.String("pigment", "stripes", "pigment pattern of the shell scene: stripes or waves")
+	objFile      = flag.String("obj", "", "also save the shell of the shell scene, with a solid wall, to this .obj file")
+	zmLayoutFile = flag.String("layout", "", "layout file of the zonemortalis scene, as saved by -zmexport")
+	zmExport     = flag.String("zmexport", "", "lay out a zonemortalis board and save it as this basename with .txt, .map and .svg instead of rendering")
+	zmSeed       = flag.Int64("seed", 0, "seed of the zonemortalis layout solver; 0 seeds it from the clock")
+	frames       = flag.Int("frames", 0, "number of frames to render along the camera path; 0 renders a still to out.png")
+	pathName     = flag.String("path", "orbit", "camera path of the frames: orbit, flythrough or a file with a line per key: time, from x y z, to x y z, fov in degrees")
+	interp       = flag.String("interp", "catmullrom", "interpolation between keys of a camera path file: catmullrom or bezier")
+	frameDir     = flag.String("out", "frames", "directory to save numbered frames in")
 )
 
 func main() {
+	flag.Parse()
+
+	if *zmExport != "" {
+		if err := ExportZoneMortalis(ZoneMortalisParameters{layoutFile: *zmLayoutFile, seed: *zmSeed}, *zmExport); err != nil {
+			fmt.Printf("Error exporting board: %s \n", err.Error())
+		}
+		return
+	}
 
 	fmt.Println("Creating scene...")
 	m.SIMD_ENABLED = true
-	camera := m.NewPerspectiveCamera(width, height, 0.5*math.Pi)
+	var fov float32 = 0.5 * math.Pi
+	camera := m.NewPerspectiveCamera(width, height, fov)
 	scene := m.NewScene(camera)
 
-	pointLight := m.NewPointLight(m.Vector{0, 10, -100}, m.NewColor(255, 255, 255), 500000)
-	pointLight2 := m.NewPointLight(m.Vector{0, 10, 100}, m.NewColor(255, 255, 255), 500000)
-	scene.AddLights(pointLight, pointLight2)
-
 	//l1 := m.NewDistantLight(m.Vector{-1, -1, 1}, m.NewColor(255, 255, 255), 20)
 	//l2 := m.NewDistantLight(m.Vector{1, -1, 1}, m.NewColor(255, 255, 255), 20)
 	// l2 := m.NewPointLight(m.Vector{-2, 4.5, 7}, m.NewColor(255, 255, 255), 500)
@@ -42,86 +59,52 @@ func main() {
 
 	//m.SetBackgroundColor(m.NewColor(15, 200, 215))
 
-	q := m.Quadrilateral{P1: m.Vector{-5.0, -5.0, 0.0}, P2: m.Vector{5.0, -5.0, 0.0}, P3: m.Vector{5.0, 5.0, 0.0}, P4: m.Vector{-5.0, -5.0, 0.0}}
-	points := poisson(q, 1.0)
-	sites := make([]voronoi.Vertex, len(points))
-	for i, p := range points {
-		sites[i] = voronoi.Vertex{float64(p.X), float64(p.Y)}
-	}
-	bbox := voronoi.NewBBox(-5.0, 5.0, -5.0, 5.0)
-	diagram := voronoi.ComputeDiagram(sites, bbox, true)
-	cells := make([][]m.Vector, len(diagram.Cells))
-	for i, d := range diagram.Cells {
-		cell := make([]m.Vector, len(d.Halfedges))
-		he0 := d.Halfedges[0].Edge
-		he1 := d.Halfedges[1].Edge
-
-		// all of this shit because although halfedges are ordered,
-		// their Va and Vb vertices are not...
-
-		e0va := m.Vector{float32(he0.Va.Vertex.X), float32(he0.Va.Vertex.Y), 0}
-		e0vb := m.Vector{float32(he0.Vb.Vertex.X), float32(he0.Vb.Vertex.Y), 0}
-		e1va := m.Vector{float32(he1.Va.Vertex.X), float32(he1.Va.Vertex.Y), 0}
-		e1vb := m.Vector{float32(he1.Vb.Vertex.X), float32(he1.Vb.Vertex.Y), 0}
-
-		a0a1 := e0va.Sub(e1va).Length()
-		a0b1 := e0va.Sub(e1vb).Length()
-		b0a1 := e0vb.Sub(e1va).Length()
-		b0b1 := e0vb.Sub(e1vb).Length()
-
-		var prev m.Vector
-
-		// find the first 2 vertices by finding the duplicate between va/vb of first 2 halfedges
-
-		if a0a1 == 0 {
-			cell[0] = e0vb
-			cell[1] = e0va
-			prev = e0va
-		} else if a0b1 == 0 {
-			cell[0] = e0vb
-			cell[1] = e0va
-			prev = e0va
-		} else if b0a1 == 0 {
-			cell[0] = e0va
-			cell[1] = e0vb
-			prev = e0vb
-		} else if b0b1 == 0 {
-			cell[0] = e0va
-			cell[1] = e0vb
-			prev = e0vb
-		}
-
-		for j, he := range d.Halfedges[2:] {
-			va := m.Vector{float32(he.Edge.Va.Vertex.X), float32(he.Edge.Va.Vertex.Y), 0}
-			vb := m.Vector{float32(he.Edge.Vb.Vertex.X), float32(he.Edge.Vb.Vertex.Y), 0}
-
-			valen := prev.Sub(va).Length()
-			vblen := prev.Sub(vb).Length()
-			next := va
-			if vblen != 0 && vblen < valen {
-				next = vb
-			}
-
-			cell[j+2] = next
-			prev = next
-		}
-		cells[i] = cell
+	if _, ok := cellPatterns[*pattern]; !ok {
+		fmt.Printf("Unknown cell pattern: %s \n", *pattern)
+		return
+	}
+	if _, ok := pigmentPatterns[*pigment]; !ok {
+		fmt.Printf("Unknown pigment pattern: %s \n", *pigment)
+		return
 	}
 
-	for _, cell := range cells {
-		mat := m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)))})
-		depth := -5 * rand.Float32()
-		esf := gen.ExtrudeSolidFace(cell, m.Vector{0, 0, depth}, mat)
-		scene.Add(esf)
+	var from, to m.Vector
+	var err error
+	switch *sceneName {
+	case "voronoi":
+		from, to = voronoiScene(scene)
+	case "causeway":
+		from, to = causewayScene(scene)
+	case "paving":
+		from, to = pavingScene(scene)
+	case "shatter":
+		from, to = shatterScene(scene)
+	case "pointcloud":
+		from, to = pointCloudScene(scene)
+	case "morphospace":
+		from, to = morphospaceScene(scene)
+	case "shell":
+		from, to = shellScene(scene)
+	case "nave":
+		from, to, err = naveScene(scene)
+	case "zonemortalis":
+		from, to, err = zoneMortalisScene(scene)
+	default:
+		fmt.Printf("Unknown scene: %s \n", *sceneName)
+		return
 	}
-    /*
-	mat := &m.DiffuseMaterial{Color: m.NewColor(255,0,0)}
-    bunny, err := LoadObj("bunny.obj", mat)
 	if err != nil {
-		fmt.Printf("Error reading file: %s \n", err.Error())
+		fmt.Printf("Error creating scene: %s \n", err.Error())
+		return
 	}
-    scene.Add(bunny)
-    */
+	/*
+		mat := &m.DiffuseMaterial{Color: m.NewColor(255,0,0)}
+		bunny, err := LoadObj("bunny.obj", mat)
+		if err != nil {
+			fmt.Printf("Error reading file: %s \n", err.Error())
+		}
+		scene.Add(bunny)
+	*/
 
 	radmat := m.NewRadiantMaterial(m.ConstantTexture{Color: m.NewColor(176, 237, 255)})
 	skybox := m.NewCuboid(m.NewAABB(m.Vector{-1000, -1000, -1000}, m.Vector{1000, 1000, 1000}), radmat)
@@ -129,13 +112,12 @@ func main() {
 	//triangles := skybox.Tesselate()
 	skyboxObject := m.NewTriangleComplexObject(triangles)
 	scene.Add(skyboxObject)
-	scene.Emitters = triangles
+	scene.Emitters = append(scene.Emitters, triangles...)
 	scene.Precompute()
 
 	fmt.Println("Rendering...")
 
-    from, to := m.Vector{0, 1, -10}, m.Vector{0, 0, 10}
-    //from, to := m.Vector{-0.2, 0.2, 0.2}, m.Vector{-0.08813016500000001, 0.14181918999999998, 0.011103720000000001}
+	//from, to := m.Vector{-0.2, 0.2, 0.2}, m.Vector{-0.08813016500000001, 0.14181918999999998, 0.011103720000000001}
 	camera.LookAt(from, to, ey)
 
 	params := render.Params{
@@ -144,8 +126,272 @@ func main() {
 		NumSamples:   numSamples,
 		AntiAliasing: true,
 		//TracerType: 	m.WhittedStyle,
-		TracerType:   m.PathNextEventEstimate,
+		TracerType: m.PathNextEventEstimate,
+	}
+	if *frames > 0 {
+		renderFrames(params, from, to, fov)
+		return
 	}
 	film := render.Render(params)
 	film.SaveAsPNG("out.png")
 }
+
+// renderFrames renders the scene along the camera path picked by flags,
+// starting from the camera of the scene, and saves the frames as numbered pngs
+func renderFrames(params render.Params, from, to m.Vector, fov float32) {
+	var path cameraPath
+	switch *pathName {
+	case "orbit":
+		path = orbitPath(from, to, fov, 1)
+	case "flythrough":
+		path = flythroughPath(from, to, fov, 1)
+	default:
+		interpolations := map[string]interpolation{"catmullrom": catmullRom, "bezier": bezier}
+		i, ok := interpolations[*interp]
+		if !ok {
+			fmt.Printf("Unknown interpolation: %s \n", *interp)
+			return
+		}
+		var err error
+		path, err = LoadCameraPath(*pathName, i)
+		if err != nil {
+			fmt.Printf("Error reading camera path: %s \n", err.Error())
+			return
+		}
+	}
+	if err := os.MkdirAll(*frameDir, 0755); err != nil {
+		fmt.Printf("Error creating frame directory: %s \n", err.Error())
+		return
+	}
+	for i := 0; i < *frames; i++ {
+		from, to, fov := path.at(path.frameTime(i, *frames))
+		camera := m.NewPerspectiveCamera(width, height, fov)
+		camera.LookAt(from, to, ey)
+		params.Scene.Camera = camera
+		fmt.Printf("Rendering frame %d/%d...\n", i+1, *frames)
+		film := render.Render(params)
+		film.SaveAsPNG(filepath.Join(*frameDir, fmt.Sprintf("frame%04d.png", i)))
+	}
+}
+
+// voronoiScene adds extruded voronoi cells and returns camera from/to
+func voronoiScene(scene *m.Scene) (m.Vector, m.Vector) {
+	pointLight := m.NewPointLight(m.Vector{0, 10, -100}, m.NewColor(255, 255, 255), 500000)
+	pointLight2 := m.NewPointLight(m.Vector{0, 10, 100}, m.NewColor(255, 255, 255), 500000)
+	scene.AddLights(pointLight, pointLight2)
+
+	preset := cellPatterns[*pattern]
+	_, cells := cellPattern(preset(m.Vector{-5.0, -5.0, 0}, m.Vector{5.0, 5.0, 0}, rand.Int63()))
+
+	for _, cell := range cells {
+		if cell == nil {
+			continue
+		}
+		mat := m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)))})
+		depth := -5 * rand.Float32()
+		esf := gen.ExtrudeSolidFace(cell, m.Vector{0, 0, depth}, mat)
+		scene.Add(esf)
+	}
+	return m.Vector{0, 1, -10}, m.Vector{0, 0, 10}
+}
+
+// causewayScene adds basalt columns of varying height, like the Giant's Causeway,
+// and returns camera from/to
+func causewayScene(scene *m.Scene) (m.Vector, m.Vector) {
+	l1 := m.NewPointLight(m.Vector{-20, 30, -20}, m.NewColor(255, 255, 255), 500000)
+	scene.AddLights(l1)
+
+	min, max := m.Vector{-5.0, -5.0, 0}, m.Vector{5.0, 5.0, 0}
+	seed := rand.Int63()
+	_, cells := cellPattern(cellPatterns["basalt"](min, max, seed))
+	scene.Add(extrudeCells(cells, columnParams{
+		material:  m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(60, 60, 65)}),
+		height:    noiseDensity(4, seed),
+		minHeight: 0.2,
+		maxHeight: 3.0,
+		grout:     0.06,
+		bevel:     0.04,
+	}))
+	ground := m.NewCuboid(m.NewAABB(m.Vector{-5.0, -0.1, -5.0}, m.Vector{5.0, 0.0, 5.0}), m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(40, 35, 30)}))
+	scene.Add(m.NewTriangleComplexObject(ground.Tesselate()))
+	return m.Vector{-2, 6, -9}, m.Vector{0, 1, 0}
+}
+
+// pavingScene adds paving stones set in mortar and returns camera from/to
+func pavingScene(scene *m.Scene) (m.Vector, m.Vector) {
+	l1 := m.NewPointLight(m.Vector{-20, 30, -20}, m.NewColor(255, 255, 255), 500000)
+	scene.AddLights(l1)
+
+	min, max := m.Vector{-5.0, -5.0, 0}, m.Vector{5.0, 5.0, 0}
+	seed := rand.Int63()
+	_, cells := cellPattern(cellPatterns["cobblestone"](min, max, seed))
+	scene.Add(extrudeCells(cells, columnParams{
+		material:  m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(150, 140, 125)}),
+		height:    noiseDensity(0.5, seed),
+		minHeight: 0.12,
+		maxHeight: 0.2,
+		grout:     0.08,
+		bevel:     0.05,
+	}))
+	mortar := m.NewCuboid(m.NewAABB(m.Vector{-5.0, -0.1, -5.0}, m.Vector{5.0, 0.06, 5.0}), m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(190, 185, 175)}))
+	scene.Add(m.NewTriangleComplexObject(mortar.Tesselate()))
+	return m.Vector{0, 4, -7}, m.Vector{0, 0, 0}
+}
+
+// shatterScene adds the bunny broken into voronoi fragments flying apart,
+// and returns camera from/to
+func shatterScene(scene *m.Scene) (m.Vector, m.Vector) {
+	l1 := m.NewPointLight(m.Vector{-10, 20, -20}, m.NewColor(255, 255, 255), 200000)
+	scene.AddLights(l1)
+
+	outside := m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(230, 225, 215)})
+	inside := m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(200, 90, 60)})
+	bunny, err := LoadObjTriangles("bunny.obj", outside)
+	if err != nil {
+		fmt.Printf("Error reading file: %s, shattering a cube instead \n", err.Error())
+		bunny = m.NewCuboid(m.NewAABB(m.Vector{-0.07, -0.07, -0.07}, m.Vector{0.07, 0.07, 0.07}), outside).Tesselate()
+	}
+	// the bunny is about 0.15 across
+	bunny = trianglesFromObject(transformObject(m.NewTriangleComplexObject(bunny), m.Translate(m.Vector{0, 2, 0}).Mul(m.ScaleUniform(20))))
+
+	b := m.NewTriangleComplexObject(bunny).Bound(m.ScaleUniform(1.0))
+	seeds := []m.Vector{}
+	for _, p := range poissonBox(b.Pmin, b.Pmax, 0.5, 30, nil) {
+		if insideMesh(bunny, p) {
+			seeds = append(seeds, p)
+		}
+	}
+	scene.Add(explode(fracture(bunny, seeds, inside), b.Centroid(), 0.4))
+
+	ground := m.NewCuboid(m.NewAABB(m.Vector{-10, -0.1, -10}, m.Vector{10, 0, 10}), m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(90, 90, 100)}))
+	scene.Add(m.NewTriangleComplexObject(ground.Tesselate()))
+	return m.Vector{0, 3, -6}, m.Vector{0, 2, 0}
+}
+
+// pointCloudScene adds the bunny as beads spread evenly over its surface,
+// and returns camera from/to
+func pointCloudScene(scene *m.Scene) (m.Vector, m.Vector) {
+	l1 := m.NewPointLight(m.Vector{-10, 20, -20}, m.NewColor(255, 255, 255), 200000)
+	scene.AddLights(l1)
+
+	mat := m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(40, 120, 200)})
+	bunny, err := LoadObjTriangles("bunny.obj", mat)
+	if err != nil {
+		fmt.Printf("Error reading file: %s \n", err.Error())
+		return m.Vector{0, 3, -6}, m.Vector{0, 2, 0}
+	}
+	bunny = trianglesFromObject(transformObject(m.NewTriangleComplexObject(bunny), m.Translate(m.Vector{0, 2, 0}).Mul(m.ScaleUniform(20))))
+	var r float32 = 0.06
+	beads := []m.Object{}
+	for _, s := range poissonMesh(bunny, r, 30, true, nil) {
+		beads = append(beads, m.NewSphere(s.position, 0.3*r, mat))
+	}
+	scene.Add(m.NewComplexObject(beads))
+
+	ground := m.NewCuboid(m.NewAABB(m.Vector{-10, -0.1, -10}, m.Vector{10, 0, 10}), m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(90, 90, 100)}))
+	scene.Add(m.NewTriangleComplexObject(ground.Tesselate()))
+	return m.Vector{0, 3, -6}, m.Vector{0, 2, 0}
+}
+
+// morphospaceScene adds a Raup cube of shells and returns camera from/to.
+// The shells are not labelled in the render; their parameters are printed instead
+func morphospaceScene(scene *m.Scene) (m.Vector, m.Vector) {
+	l1 := m.NewPointLight(m.Vector{-10, 20, -20}, m.NewColor(255, 255, 255), 500000)
+	l2 := m.NewPointLight(m.Vector{10, 20, -20}, m.NewColor(255, 255, 255), 500000)
+	scene.AddLights(l1, l2)
+
+	params := morphospaceParams{
+		flare:       paramRange{min: 1.5, max: 4.0, n: 4},
+		verm:        paramRange{min: 0.0, max: 0.6, n: 3},
+		spire:       paramRange{min: 0.5, max: 4.0, n: 3},
+		numWindings: 5,
+		cellSize:    1.0,
+		spacing:     1.5,
+	}
+	fmt.Print(params.legend())
+	scene.Add(morphospace(params))
+	return m.Vector{-4, 4, -7}, m.Vector{0, 0, 0}
+}
+
+// shellScene adds a single shell with a reaction-diffusion pigment pattern
+// and returns camera from/to
+func shellScene(scene *m.Scene) (m.Vector, m.Vector) {
+	l1 := m.NewPointLight(m.Vector{-10, 20, -20}, m.NewColor(255, 255, 255), 50000)
+	scene.AddLights(l1)
+
+	img := meinhardt(pigmentPatterns[*pigment](shellNumPoints, 400, rand.Int63()))
+	tex := m.NewImageTexture(img, m.TriangleMeshUVFunc)
+	shell := generateTexturedShell(2.0, 0.1, 2.5, 6, 6.0, tex)
+	if *objFile != "" {
+		solid, err := generateSolidShell(2.0, 0.1, 2.5, 6, 0.1)
+		if err == nil {
+			err = os.WriteFile(*objFile, []byte(SaveObj(solid)), 0644)
+		}
+		if err != nil {
+			fmt.Printf("Error saving shell: %s \n", err.Error())
+		}
+	}
+	// rotate so that the shell's axis points up
+	scene.Add(m.NewSharedObject(shell, m.RotateX(math.Pi/2.0)))
+	return m.Vector{0, 2, -8}, m.Vector{0, 0, 0}
+}
+
+// naveScene adds a gothic nave with aisles and returns camera from/to
+func naveScene(scene *m.Scene) (m.Vector, m.Vector, error) {
+	l1 := m.NewPointLight(m.Vector{30, 40, -30}, m.NewColor(255, 255, 255), 500000)
+	scene.AddLights(l1)
+
+	mat := m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(200, 190, 170)})
+	obj, glass, err := nave(naveParams{
+		material:         mat,
+		numBays:          5,
+		bayWidth:         4,
+		naveWidth:        8,
+		aisleWidth:       4,
+		aisleHeight:      6,
+		arcadeHeight:     8,
+		clerestoryHeight: 5,
+		roofHeight:       4,
+		wallThickness:    0.5,
+		pillarRadius:     0.5,
+		buttressDepth:    1.5,
+		excess:           1.0,
+		numPoints:        10,
+		facadeLevels:     1,
+		molding:          filletedRollProfile(0.05, 6),
+		glass: stainedGlassParams{
+			coloring: paletteColoring{palette: []m.Color{
+				m.NewColor(180, 30, 40),
+				m.NewColor(30, 60, 170),
+				m.NewColor(220, 170, 40),
+				m.NewColor(40, 130, 60),
+			}},
+		},
+	})
+	if err != nil {
+		return m.Vector{}, m.Vector{}, err
+	}
+	scene.Add(obj)
+	// the glass lights the inside of the nave
+	scene.Emitters = append(scene.Emitters, glass...)
+	return m.Vector{20, 12, -20}, m.Vector{0, 6, 10}, nil
+}
+
+// zoneMortalisScene adds a board of zone mortalis tiles, from the -layout file
+// if there is one or else laid out from -seed, and returns camera from/to
+func zoneMortalisScene(scene *m.Scene) (m.Vector, m.Vector, error) {
+	l1 := m.NewPointLight(m.Vector{4, 15, -3}, m.NewColor(255, 255, 255), 30000)
+	scene.AddLights(l1)
+
+	// tiles of 3 units keep the board of 4x4 tiles well within the skybox
+	board, err := NewZoneMortalis(ZoneMortalisParameters{
+		tileSize:   3,
+		layoutFile: *zmLayoutFile,
+		seed:       *zmSeed,
+	})
+	if err != nil {
+		return m.Vector{}, m.Vector{}, err
+	}
+	scene.Add(board)
+	return m.Vector{6, 8, -3}, m.Vector{6, 0, 5}, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// a moldingProfile is the cross-section of the front of a tracery bar, from its outer
+// edge to its inner edge. The sides and back of the bar are flat.
+// nil means a flat front, which is a straight extrusion of the outline.
+type moldingProfile []moldingPoint
+
+// u runs across the bar from its outer edge (0) to its inner edge (1), and
+// z goes into the wall from the front; both are a fraction of the width of the bar
+type moldingPoint struct {
+	u, z float32
+}
+
+// chamferProfile cuts off both front edges of the bar at 45 degrees
+func chamferProfile(size float32) moldingProfile {
+	return moldingProfile{{0, size}, {size, 0}, {1 - size, 0}, {1, size}}
+}
+
+// rollProfile gives the bar a half round front
+func rollProfile(numPoints int) moldingProfile {
+	profile := make(moldingProfile, numPoints)
+	for i := 0; i < numPoints; i++ {
+		t := math.Pi + float64(i)/float64(numPoints-1)*math.Pi
+		profile[i] = moldingPoint{
+			u: 0.5 + 0.5*float32(math.Cos(t)),
+			z: 0.5 + 0.5*float32(math.Sin(t)),
+		}
+	}
+	return profile
+}
+
+// filletedRollProfile is a roll in the middle of the bar, chamfered back
+// to the sides, with a flat fillet running along the front of the roll
+func filletedRollProfile(fillet float32, numPoints int) moldingProfile {
+	profile := moldingProfile{{0, 0.6}}
+	for i := 0; i < numPoints; i++ {
+		t := math.Pi + float64(i)/float64(numPoints-1)*math.Pi
+		p := moldingPoint{
+			u: 0.5 + 0.3*float32(math.Cos(t)),
+			z: 0.3 + 0.3*float32(math.Sin(t)),
+		}
+		if p.z < fillet {
+			p.z = fillet
+		}
+		profile = append(profile, p)
+	}
+	return append(profile, moldingPoint{1, 0.6})
+}
+
+// sweepBar returns a tracery bar between the outer and inner outline of its front,
+// both in the XY plane at z=0 with the same number of points, with the profile
+// swept along them. Closed outlines form a loop, open ones get flat ends.
+func sweepBar(mat m.Material, outer, inner []m.Vector, depth float32, profile moldingProfile, closed bool) m.Object {
+	outer, inner = dedupOutlines(outer, inner, closed)
+	// faces point outwards if the bar lies to the left of its outer outline
+	mid := len(outer) / 2
+	across := m.VectorFromTo(outer[mid], inner[mid])
+	along := m.VectorFromTo(outer[maxInt(mid-1, 0)], outer[minInt(mid+1, len(outer)-1)])
+	if across.Cross(along).Z > 0 {
+		outer, inner = reversePoints(outer), reversePoints(inner)
+	}
+
+	rings := make([][]m.Vector, len(outer))
+	for i := range outer {
+		across := m.VectorFromTo(outer[i], inner[i])
+		width := across.Length()
+		ring := []m.Vector{}
+		for _, p := range profile {
+			z := p.z * width
+			if z > depth {
+				z = depth
+			}
+			ring = append(ring, outer[i].Add(across.Times(p.u)).Add(ez.Times(z)))
+		}
+		ring = append(ring, inner[i].Add(ez.Times(depth)), outer[i].Add(ez.Times(depth)))
+		rings[i] = ring
+	}
+	if closed {
+		rings = append(rings, rings[0])
+	}
+	triangles := joinRings(rings, mat)
+	if !closed {
+		triangles = append(triangles, capRing(reversePoints(rings[0]), mat)...)
+		triangles = append(triangles, capRing(rings[len(rings)-1], mat)...)
+	}
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// capRing closes a ring of the sweep with a fan around its centroid
+func capRing(ring []m.Vector, mat m.Material) []m.Triangle {
+	var c m.Vector
+	for _, p := range ring {
+		c = c.Add(p)
+	}
+	c = c.Times(1.0 / float32(len(ring)))
+	triangles := make([]m.Triangle, len(ring))
+	for i, p := range ring {
+		triangles[i] = m.NewTriangle(c, p, ring[(i+1)%len(ring)], mat)
+	}
+	return triangles
+}
+
+// dedupOutlines removes points where both outlines repeat themselves,
+// which would otherwise make degenerate rings
+func dedupOutlines(outer, inner []m.Vector, closed bool) ([]m.Vector, []m.Vector) {
+	const epsilon = 1e-5
+	same := func(i, j int) bool {
+		return m.VectorFromTo(outer[i], outer[j]).Length() < epsilon && m.VectorFromTo(inner[i], inner[j]).Length() < epsilon
+	}
+	o, in := []m.Vector{outer[0]}, []m.Vector{inner[0]}
+	last := 0
+	for i := 1; i < len(outer); i++ {
+		if same(last, i) {
+			continue
+		}
+		o, in = append(o, outer[i]), append(in, inner[i])
+		last = i
+	}
+	if closed && len(o) > 1 && same(last, 0) {
+		o, in = o[:len(o)-1], in[:len(in)-1]
+	}
+	return o, in
+}
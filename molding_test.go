@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+func TestSweepBarWatertight(t *testing.T) {
+	circle := func(r float32, from, to float64) []m.Vector {
+		return gen.NewCircle(m.Vector{}, r).PointsPhaseRange(from, to, 20, ex, ey)
+	}
+	for i, tt := range []struct {
+		outer, inner []m.Vector
+		profile      moldingProfile
+		closed       bool
+	}{
+		{
+			outer:   circle(1, 0, 2*math.Pi),
+			inner:   circle(0.9, 0, 2*math.Pi),
+			profile: chamferProfile(0.2),
+			closed:  true,
+		},
+		{
+			// same ring, but running the other way around
+			outer:   circle(1, 2*math.Pi, 0),
+			inner:   circle(0.9, 2*math.Pi, 0),
+			profile: rollProfile(8),
+			closed:  true,
+		},
+		{
+			outer:   circle(1, 0, math.Pi),
+			inner:   circle(0.9, 0, math.Pi),
+			profile: filletedRollProfile(0.05, 8),
+			closed:  false,
+		},
+	} {
+		bar := sweepBar(&m.DiffuseMaterial{}, tt.outer, tt.inner, 0.2, tt.profile, tt.closed)
+		if err := watertight(trianglesFromObject(bar)); err != nil {
+			t.Errorf("%d): %v", i, err)
+		}
+		// the front of the bar faces the camera
+		ray := m.NewRay(m.Vector{0.95, 0.01, -5}, ez)
+		if si, hit := bar.Intersect(ray); !hit || si.GetNormal().Dot(ray.Direction) >= 0 {
+			t.Errorf("%d): ray should hit the front of the bar", i)
+		}
+	}
+}
+
+func TestMouldedTracery(t *testing.T) {
+	window := gothicWindowParams{
+		material:  &m.DiffuseMaterial{},
+		width:     2,
+		height:    3,
+		springing: 1.5,
+		margin:    0.5,
+		depth:     0.3,
+		numPoints: 10,
+		transform: m.ScaleUniform(1.0),
+	}
+	for i, tt := range []struct {
+		profile moldingProfile
+		flat    bool
+	}{
+		{profile: nil, flat: true},
+		{profile: rollProfile(8), flat: false},
+	} {
+		tracery := gothicTraceryParams{
+			outerWidth:     0.1,
+			innerWidth:     0.05,
+			verticalOffset: 0.2,
+			numFoils:       3,
+			depth:          0.1,
+			molding:        tt.profile,
+		}
+		obj, err := gothicWindowTracery(window, tracery)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// scan across the jambs and the mullion below the springing line:
+		// a flat front always faces straight at us, a moulded one does not
+		hits, flat := 0, true
+		for x := float32(-1.1); x < 1.1; x += 0.005 {
+			si, hit := obj.Intersect(m.NewRay(m.Vector{x, 1, -5}, ez))
+			if !hit {
+				continue
+			}
+			hits++
+			if n := si.GetNormal(); !approx(n.X, 0) || !approx(n.Y, 0) {
+				flat = false
+			}
+		}
+		if hits == 0 {
+			t.Errorf("%d): expected to hit the tracery", i)
+		}
+		if flat != tt.flat {
+			t.Errorf("%d): got a flat front %t want %t", i, flat, tt.flat)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// a nave is built from bays along the z-axis, starting at the west facade at z=0
+// each bay has an arcade of pillars and arches separating the nave from its aisles,
+// a clerestory wall with windows above the arcade and an aisle wall with windows,
+// supported by buttresses. The nave has a pitched roof and the aisles lean-to roofs.
+// Seen from the west, with outer = naveWidth/2 + aisleWidth:
+//
+//	| aisle |     nave     | aisle |
+//	x=-outer   x=0   x=naveWidth/2  x=outer
+type naveParams struct {
+	material m.Material
+	numBays  int
+	// length of a bay along the nave
+	bayWidth float32
+	// width of the nave between the arcades, and of each aisle
+	// aisleWidth 0 means no aisles, the arcade is then a closed window wall
+	naveWidth  float32
+	aisleWidth float32
+	// heights of the aisle walls, the arcade and the clerestory above it
+	aisleHeight      float32
+	arcadeHeight     float32
+	clerestoryHeight float32
+	// height of the roof ridge above the clerestory
+	roofHeight    float32
+	wallThickness float32
+	pillarRadius  float32
+	// how far buttresses stick out from the walls
+	buttressDepth float32
+	// ratio r / distance(pL, pR) for all arches, excess >= 0.5
+	excess float32
+	// number of points on a quarter circle arc
+	numPoints int
+	// whether to cover the nave with rib vaults springing from the arcade
+	vaulted   bool
+	vaultType vaultType
+	// number of times the tracery of the facade windows subdivides its sub arches
+	facadeLevels int
+	// stained glass in the windows, none if its coloring is nil
+	glass stainedGlassParams
+	// cross-section of the tracery bars, flat if nil
+	molding moldingProfile
+}
+
+// nave returns the nave and, separately, the triangles of its stained glass
+// so that they can be added to the emitters of a scene. The glass is also part
+// of the returned object.
+func nave(params naveParams) (m.Object, []m.Triangle, error) {
+	mat, t := params.material, params.wallThickness
+	length := float32(params.numBays) * params.bayWidth
+	wallTop := params.arcadeHeight + params.clerestoryHeight
+	halfNave := params.naveWidth / 2.0
+	outer := halfNave + params.aisleWidth
+
+	clerestory, clerestoryGlass, err := windowBay(params, params.bayWidth, params.clerestoryHeight, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	var aisleWall, arcade m.Object
+	var aisleGlass, arcadeGlass []m.Triangle
+	if params.aisleWidth > 0 {
+		aisleWall, aisleGlass, err = windowBay(params, params.bayWidth, params.aisleHeight, 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		arcade, err = arcadeBay(params)
+	} else {
+		arcade, arcadeGlass, err = windowBay(params, params.bayWidth, params.arcadeHeight, 0)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	objects := []m.Object{}
+	glass := []m.Triangle{}
+	for i := 0; i < params.numBays; i++ {
+		z := (float32(i) + 0.5) * params.bayWidth
+		for _, side := range []float32{-1, 1} {
+			objects = append(objects, alongNave(arcade, side*halfNave, 0, z))
+			objects = append(objects, alongNave(clerestory, side*halfNave, params.arcadeHeight, z))
+			glass = append(glass, transformTriangles(arcadeGlass, alongNaveTransform(side*halfNave, 0, z))...)
+			glass = append(glass, transformTriangles(clerestoryGlass, alongNaveTransform(side*halfNave, params.arcadeHeight, z))...)
+			if aisleWall != nil {
+				objects = append(objects, alongNave(aisleWall, side*outer, 0, z))
+				glass = append(glass, transformTriangles(aisleGlass, alongNaveTransform(side*outer, 0, z))...)
+			}
+		}
+	}
+
+	if params.vaulted {
+		vault, err := ribVault(vaultParams{
+			material:  mat,
+			vaultType: params.vaultType,
+			width:     params.naveWidth - t,
+			length:    params.bayWidth,
+			rise:      params.clerestoryHeight,
+			ribWidth:  t / 2.0,
+			ribDepth:  t / 2.0,
+			numPoints: params.numPoints,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := 0; i < params.numBays; i++ {
+			z := (float32(i) + 0.5) * params.bayWidth
+			objects = append(objects, m.NewSharedObject(vault, m.Translate(m.Vector{0, params.arcadeHeight, z})))
+		}
+	}
+
+	// pillars and buttresses at the boundaries between bays
+	outerHeight := params.aisleHeight
+	if params.aisleWidth == 0 {
+		outerHeight = wallTop
+	}
+	for i := 0; i <= params.numBays; i++ {
+		z := float32(i) * params.bayWidth
+		for _, side := range []float32{-1, 1} {
+			if params.aisleWidth > 0 {
+				objects = append(objects, pillar(mat, m.Vector{side * halfNave, 0, z}, params.pillarRadius, params.arcadeHeight))
+			}
+			objects = append(objects, buttress(params, m.Vector{side * outer, 0, z}, side, outerHeight))
+		}
+	}
+
+	// facades at both ends, and walls closing the aisles
+	facade, facadeGlass, err := windowBay(params, params.naveWidth, wallTop, params.facadeLevels)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, z := range []float32{-t / 2.0, length + t/2.0} {
+		objects = append(objects, m.NewSharedObject(facade, m.Translate(m.Vector{0, 0, z})))
+		glass = append(glass, transformTriangles(facadeGlass, m.Translate(m.Vector{0, 0, z}))...)
+	}
+	if params.aisleWidth > 0 {
+		for _, side := range []float32{-1, 1} {
+			xmin, xmax := side*halfNave, side*outer
+			for _, z := range []float32{-t, length} {
+				box := m.NewCuboid(m.NewAABB(m.Vector{xmin, 0, z}, m.Vector{xmax, params.aisleHeight, z + t}), mat)
+				objects = append(objects, m.NewTriangleComplexObject(box.Tesselate()))
+			}
+		}
+	}
+
+	// pitched roof with gables, lean-to roofs over the aisles
+	ridge := wallTop + params.roofHeight
+	objects = append(objects, slab(mat, t,
+		m.Vector{-halfNave - t, wallTop, -t}, m.Vector{0, ridge, -t},
+		m.Vector{0, ridge, length + t}, m.Vector{-halfNave - t, wallTop, length + t}))
+	objects = append(objects, slab(mat, t,
+		m.Vector{0, ridge, -t}, m.Vector{halfNave + t, wallTop, -t},
+		m.Vector{halfNave + t, wallTop, length + t}, m.Vector{0, ridge, length + t}))
+	for _, z := range []float32{-t, length} {
+		gable := []m.Vector{{halfNave, wallTop, z}, {0, ridge, z}, {-halfNave, wallTop, z}}
+		objects = append(objects, gen.ExtrudeSolidFace(gable, m.Vector{0, 0, t}, mat))
+	}
+	if params.aisleWidth > 0 {
+		for _, side := range []float32{-1, 1} {
+			objects = append(objects, slab(mat, t,
+				m.Vector{side * (outer + t), params.aisleHeight, -t}, m.Vector{side * halfNave, params.arcadeHeight, -t},
+				m.Vector{side * halfNave, params.arcadeHeight, length + t}, m.Vector{side * (outer + t), params.aisleHeight, length + t}))
+		}
+	}
+	if len(glass) > 0 {
+		objects = append(objects, m.NewTriangleComplexObject(glass))
+	}
+	return m.NewComplexObject(objects), glass, nil
+}
+
+// alongNave places a bay built in the XY plane, centered on x=0 and z=0,
+// rotated to run along the z-axis with its center at (x, y, z)
+func alongNave(bay m.Object, x, y, z float32) m.Object {
+	return m.NewSharedObject(bay, alongNaveTransform(x, y, z))
+}
+
+func alongNaveTransform(x, y, z float32) m.Transform {
+	return m.Translate(m.Vector{x, y, z}).Mul(m.RotateY(math.Pi / 2.0))
+}
+
+// windowBay returns a wall of width w and height h in the XY plane, centered
+// on x=0 and z=0, with a traceried pointed arch window in it whose sub arches
+// are subdivided levels times, and the stained glass of that window if any
+func windowBay(params naveParams, w, h float32, levels int) (m.Object, []m.Triangle, error) {
+	t := params.wallThickness
+	// window leaves a margin of a fifth of the wall on all sides
+	margin := w / 5.0
+	if hm := h / 5.0; hm < margin {
+		margin = hm
+	}
+	width, height := w-2*margin, h-2*margin
+	// the arch needs to fit under the top margin: see createArch
+	rise := width * float32(math.Sqrt(float64(params.excess-0.25)))
+	springing := height - rise
+	if springing < 0 {
+		springing = 0
+	}
+	window := gothicWindowParams{
+		material:  params.material,
+		width:     width,
+		height:    height,
+		springing: springing,
+		margin:    margin,
+		depth:     t,
+		numPoints: params.numPoints,
+		transform: m.Translate(m.Vector{0, margin, -t / 2.0}),
+	}
+	tracery := gothicTraceryParams{
+		outerWidth:     width / 20.0,
+		innerWidth:     width / 30.0,
+		verticalOffset: width / 10.0,
+		numFoils:       3,
+		depth:          t / 3.0,
+		molding:        params.molding,
+	}
+	// each level is about half as wide as the one above it
+	tracery.subLevels = traceryLevels(levels, width/45.0, width/20.0, 0.5, 3)
+	wall, err := gothicWindowWall(window)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := gothicWindowTracery(window, tracery)
+	if err != nil {
+		return nil, nil, err
+	}
+	var glass []m.Triangle
+	if params.glass.coloring != nil {
+		glass, err = gothicWindowGlass(window, tracery, params.glass)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return m.NewComplexObject([]m.Object{wall, obj}), glass, nil
+}
+
+// arcadeBay returns the wall between two pillars in the XY plane, centered on
+// x=0 and z=0, with an arch opening down to the floor
+func arcadeBay(params naveParams) (m.Object, error) {
+	w, h, t := params.bayWidth, params.arcadeHeight, params.wallThickness
+	openingWidth := w - 2*params.pillarRadius
+	rise := openingWidth * float32(math.Sqrt(float64(params.excess-0.25)))
+	springing := h - rise - h/10.0
+	wall, err := archWindowWall(archWindowWallParams{
+		material: params.material,
+		rectOutline: m.Quadrilateral{
+			P1: m.Vector{-w / 2.0, 0, 0},
+			P2: m.Vector{w / 2.0, 0, 0},
+			P3: m.Vector{w / 2.0, h, 0},
+			P4: m.Vector{-w / 2.0, h, 0},
+		},
+		excess:        params.excess,
+		xPadding:      params.pillarRadius,
+		bottomPadding: 0,
+		depth:         t,
+		pLpRY:         springing,
+		numPoints:     params.numPoints,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transformObject(wall, m.Translate(m.Vector{0, 0, -t / 2.0})), nil
+}
+
+// pillar returns an octagonal pillar standing on p
+func pillar(mat m.Material, p m.Vector, r, h float32) m.Object {
+	// counterclockwise seen from above, so that faces point outwards
+	points := make([]m.Vector, 8)
+	for i := 0; i < 8; i++ {
+		phi := -float64(i) * math.Pi / 4.0
+		points[i] = p.Add(m.Vector{r * float32(math.Cos(phi)), 0, r * float32(math.Sin(phi))})
+	}
+	return gen.ExtrudeSolidFace(points, m.Vector{0, h, 0}, mat)
+}
+
+// buttress returns a stepped buttress against a wall running along the z-axis
+// at p, sticking out in direction side (-1 or 1) along the x-axis
+func buttress(params naveParams, p m.Vector, side, h float32) m.Object {
+	d, w := params.buttressDepth, params.wallThickness
+	lower := m.NewAABB(m.Vector{p.X, 0, p.Z - w}, m.Vector{p.X + side*d, 0.6 * h, p.Z + w})
+	upper := m.NewAABB(m.Vector{p.X, 0.6 * h, p.Z - w}, m.Vector{p.X + side*d/2.0, h, p.Z + w})
+	triangles := m.NewCuboid(lower, params.material).Tesselate()
+	triangles = append(triangles, m.NewCuboid(upper, params.material).Tesselate()...)
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// slab returns a flat quadrilateral p1-p4 with thickness t
+func slab(mat m.Material, t float32, p1, p2, p3, p4 m.Vector) m.Object {
+	normal := m.VectorFromTo(p1, p2).Cross(m.VectorFromTo(p1, p4)).Normalize()
+	return gen.ExtrudeSolidFace([]m.Vector{p1, p2, p3, p4}, normal.Times(t), mat)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestNave(t *testing.T) {
+	for i, tt := range []struct {
+		numBays    int
+		aisleWidth float32
+		vaulted    bool
+		glazed     bool
+	}{
+		{numBays: 3, aisleWidth: 4},
+		{numBays: 5, aisleWidth: 4, vaulted: true},
+		{numBays: 2, aisleWidth: 0},
+		{numBays: 2, aisleWidth: 4, glazed: true},
+	} {
+		params := naveParams{
+			material:         &m.DiffuseMaterial{},
+			numBays:          tt.numBays,
+			bayWidth:         4,
+			naveWidth:        8,
+			aisleWidth:       tt.aisleWidth,
+			aisleHeight:      6,
+			arcadeHeight:     8,
+			clerestoryHeight: 5,
+			roofHeight:       4,
+			wallThickness:    0.5,
+			pillarRadius:     0.5,
+			buttressDepth:    1.5,
+			excess:           1.0,
+			numPoints:        6,
+			vaulted:          tt.vaulted,
+			vaultType:        quadripartiteVault,
+		}
+		if tt.glazed {
+			params.glass = stainedGlassParams{coloring: paletteColoring{}}
+			params.molding = rollProfile(6)
+		}
+		n, glass, err := nave(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		length := float32(tt.numBays) * params.bayWidth
+		outer := params.naveWidth/2.0 + tt.aisleWidth
+
+		// buttresses stick out furthest, the roof rises highest
+		b := n.Bound(m.ScaleUniform(1.0))
+		wantMin := m.Vector{-outer - params.buttressDepth, 0, -params.wallThickness}
+		wantMax := m.Vector{outer + params.buttressDepth, 0, length + params.wallThickness}
+		if !compareVector(b.Pmin, wantMin) || !approx(b.Pmax.X, wantMax.X) || !approx(b.Pmax.Z, wantMax.Z) {
+			t.Errorf("%d): got bounds %v %v want %v %v", i, b.Pmin, b.Pmax, wantMin, wantMax)
+		}
+		ridge := params.arcadeHeight + params.clerestoryHeight + params.roofHeight
+		if b.Pmax.Y < ridge || b.Pmax.Y > ridge+params.wallThickness {
+			t.Errorf("%d): got height %f want ridge %f under a roof %f thick", i, b.Pmax.Y, ridge, params.wallThickness)
+		}
+
+		// walking along the outside of a wall, beyond the eaves of the roof,
+		// we pass a buttress at each end of every bay
+		x := outer + (params.wallThickness+params.buttressDepth)/2.0
+		buttresses, inside := 0, false
+		for z := -1.0; z < float64(length)+1; z += 0.05 {
+			_, hit := n.Intersect(m.NewRay(m.Vector{x, 100, float32(z)}, m.Vector{0, -1, 0}))
+			if hit && !inside {
+				buttresses++
+			}
+			inside = hit
+		}
+		if buttresses != tt.numBays+1 {
+			t.Errorf("%d): expected %d buttresses, got %d", i, tt.numBays+1, buttresses)
+		}
+
+		if !tt.glazed {
+			if len(glass) != 0 {
+				t.Errorf("%d): expected no glass, got %d triangles", i, len(glass))
+			}
+			continue
+		}
+		// the glass sits in the walls with windows: the clerestory, the
+		// aisle walls and the facades
+		halfNave, halfWall := params.naveWidth/2.0, params.wallThickness/2.0
+		inWall := func(x, wall float32) bool {
+			return math.Abs(math.Abs(float64(x))-float64(wall)) < float64(halfWall)
+		}
+		var clerestory m.Vector
+		var largest float32
+		for _, tr := range glass {
+			c := tr.P0.Add(tr.P1).Add(tr.P2).Times(1.0 / 3.0)
+			inSide := (inWall(c.X, halfNave) || inWall(c.X, outer)) && c.Z > 0 && c.Z < length
+			inFacade := (c.Z > -2*halfWall && c.Z < 0) || (c.Z > length && c.Z < length+2*halfWall)
+			if !inSide && !inFacade {
+				t.Errorf("%d): glass at %v is not in a window wall", i, c)
+				break
+			}
+			area := m.VectorFromTo(tr.P0, tr.P1).Cross(m.VectorFromTo(tr.P0, tr.P2)).Length()
+			if c.X > 0 && inWall(c.X, halfNave) && c.Y > params.arcadeHeight && area > largest {
+				clerestory, largest = c, area
+			}
+		}
+		if largest == 0 {
+			t.Errorf("%d): expected glass in the clerestory", i)
+			continue
+		}
+		// seen from the middle of the nave, the middle of the largest pane
+		// in the clerestory is not hidden behind anything
+		from := m.Vector{0, clerestory.Y, clerestory.Z}
+		hit, ok := n.Intersect(m.NewRay(from, m.Vector{1, 0, 0}))
+		if !ok || !compareVector(hit.Point, clerestory) {
+			t.Errorf("%d): expected to see glass at %v, got %v", i, clerestory, ok)
+		}
+	}
+}
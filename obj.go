@@ -43,14 +43,14 @@ func SaveObj(o m.Object) string {
 		faces[i] = m.Face{v0, v1, v2}
 	}
 
-	s := ""
+	var b strings.Builder
 	for _, v := range vertices {
-		s += fmt.Sprintf("v %f %f %f\n", v.X, v.Y, v.Z)
+		fmt.Fprintf(&b, "v %f %f %f\n", v.X, v.Y, v.Z)
 	}
 	for _, f := range faces {
-		s += fmt.Sprintf("f %d %d %d\n", f.V0, f.V1, f.V2)
+		fmt.Fprintf(&b, "f %d %d %d\n", f.V0, f.V1, f.V2)
 	}
-	return s
+	return b.String()
 }
 
 func trianglesFromObject(objects ...m.Object) []m.Triangle {
@@ -87,7 +87,38 @@ func LoadObj(filename string, mat m.Material) (m.Object, error) {
 	return loadObj(scanner, mat)
 }
 
+// LoadObjTriangles is LoadObj returning separate triangles instead of a mesh
+func LoadObjTriangles(filename string, mat m.Material) ([]m.Triangle, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	vertices, faces, err := readObj(bufio.NewScanner(file))
+	if err != nil {
+		return nil, err
+	}
+	if len(faces) == 0 {
+		return nil, errors.New("Object list empty")
+	}
+	vertices = gen.CenterPointsOnOrigin(vertices)
+	triangles := make([]m.Triangle, len(faces))
+	for i, f := range faces {
+		triangles[i] = m.NewTriangle(vertices[f.V0], vertices[f.V1], vertices[f.V2], mat)
+	}
+	return triangles, nil
+}
+
 func loadObj(scanner *bufio.Scanner, mat m.Material) (m.Object, error) {
+	vertices, faces, err := readObj(scanner)
+	if err != nil {
+		return nil, err
+	}
+	return toObject(vertices, faces, mat)
+}
+
+func readObj(scanner *bufio.Scanner) ([]m.Vector, []m.Face, error) {
 	var vertices []m.Vector
 	var faces []m.Face
 	for scanner.Scan() {
@@ -103,20 +134,20 @@ func loadObj(scanner *bufio.Scanner, mat m.Material) (m.Object, error) {
 		case "v":
 			vertex, err := readVertex(values)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			vertices = append(vertices, vertex)
 		case "f":
 			face, err := readFace(values, int64(len(vertices)))
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			faces = append(faces, face)
 		default:
 			fmt.Printf("Unexpected line: %s", line)
 		}
 	}
-	return toObject(vertices, faces, mat)
+	return vertices, faces, nil
 }
 
 func readVertex(coordinates []string) (m.Vector, error) {
@@ -174,3 +205,20 @@ func toObject(vertices []m.Vector, faces []m.Face, mat m.Material) (m.Object, er
 	vertices = gen.CenterPointsOnOrigin(vertices)
 	return m.NewTriangleMesh(vertices, faces, mat), nil
 }
+
+// transformObject bakes transform t into the triangles of object o.
+// NOTE: a SharedObject reports intersection distances in object space, so a
+// scaled instance gets sorted wrongly against other objects in the scene.
+// Use this instead of NewSharedObject whenever the transform scales.
+func transformObject(o m.Object, t m.Transform) m.Object {
+	return m.NewTriangleComplexObject(transformTriangles(trianglesFromObject(o), t))
+}
+
+// transformTriangles returns copies of triangles with transform t applied
+func transformTriangles(triangles []m.Triangle, t m.Transform) []m.Triangle {
+	transformed := make([]m.Triangle, len(triangles))
+	for i, tr := range triangles {
+		transformed[i] = m.NewTriangle(t.Point(tr.P0), t.Point(tr.P1), t.Point(tr.P2), tr.Material)
+	}
+	return transformed
+}
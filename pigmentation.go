@@ -0,0 +1,147 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// H. Meinhardt - The Algorithmic Beauty of Sea Shells (1995)
+// Deborah R. Fowler, Hans Meinhardt, Przemyslaw Prusinkiewicz - Modeling Seashells (1992)
+
+// Pigment is laid down at the growing edge of the shell, so a shell pattern is
+// the history of a 1D reaction-diffusion system along the aperture lip over time.
+// We use the activator-inhibitor model:
+// a' = s*(a^2/b + baseA) - ra*a + Da * La
+// b' = s*a^2 - rb*b + Db * Lb + baseB
+// where L is the 1D laplacian and s the source density, which fluctuates
+// slightly per cell to break symmetry. A short-lived inhibitor gives stripes
+// along the direction of growth; a long-lived one makes the activator flare up
+// and die down, in waves that travel around the lip and leave chevrons behind
+type meinhardtInput struct {
+	// number of cells around the generating curve: image width
+	numCells int
+	// number of rows in the image, one per stepsPerRow iterations
+	numRows     int
+	stepsPerRow int
+	diffRateA   float64
+	diffRateB   float64
+	decayRateA  float64
+	decayRateB  float64
+	// basic production of activator and inhibitor
+	baseA float64
+	baseB float64
+	// relative fluctuation of the source density around decayRateA
+	fluctuation float64
+	seed        int64
+	// colors of the shell without pigment and at full activator concentration
+	background m.Color
+	pigment    m.Color
+}
+
+// meinhardt returns the pattern as an image: x runs along the cells of the ring,
+// y runs over time from top to bottom, which matches the v coordinate
+// of generateTexturedShell when used as an image texture
+func meinhardt(input meinhardtInput) image.Image {
+	n := input.numCells
+	r := rand.New(rand.NewSource(input.seed))
+	a := make([]float64, n)
+	b := make([]float64, n)
+	s := make([]float64, n)
+	for i := 0; i < n; i++ {
+		a[i] = 1.0
+		b[i] = 1.0
+		s[i] = input.decayRateA * (1 + input.fluctuation*(r.Float64()-0.5))
+	}
+
+	rows := make([][]float64, input.numRows)
+	minA, maxA := math.Inf(1), math.Inf(-1)
+	for y := 0; y < input.numRows; y++ {
+		for step := 0; step < input.stepsPerRow; step++ {
+			a, b = meinhardtLoop(a, b, s, input)
+		}
+		rows[y] = a
+		for _, ax := range a {
+			minA = math.Min(minA, ax)
+			maxA = math.Max(maxA, ax)
+		}
+	}
+
+	// pigment is relative to the range of activator concentrations reached;
+	// a field that never varies gets no pigment at all
+	img := image.NewRGBA(image.Rect(0, 0, n, input.numRows))
+	for y, row := range rows {
+		for x, ax := range row {
+			f := 0.0
+			if maxA > minA {
+				f = (ax - minA) / (maxA - minA)
+			}
+			img.Set(x, y, pigmentColor(f, input.background, input.pigment))
+		}
+	}
+	return img
+}
+
+// one explicit euler step with dt = 1 on a ring of cells,
+// diffusion rates should be below 0.5 to keep this stable
+func meinhardtLoop(a, b, s []float64, input meinhardtInput) ([]float64, []float64) {
+	n := len(a)
+	newA := make([]float64, n)
+	newB := make([]float64, n)
+	for i := 0; i < n; i++ {
+		prev, next := (i+n-1)%n, (i+1)%n
+		la := a[prev] + a[next] - 2*a[i]
+		lb := b[prev] + b[next] - 2*b[i]
+		aa := s[i] * a[i] * a[i]
+		newA[i] = a[i] + s[i]*(a[i]*a[i]/b[i]+input.baseA) - input.decayRateA*a[i] + input.diffRateA*la
+		newB[i] = b[i] + aa - input.decayRateB*b[i] + input.diffRateB*lb + input.baseB
+	}
+	return newA, newB
+}
+
+// pigmentPatterns are presets for the shell scene
+var pigmentPatterns = map[string]func(numCells, numRows int, seed int64) meinhardtInput{
+	// bands along the direction of growth
+	"stripes": func(numCells, numRows int, seed int64) meinhardtInput {
+		return meinhardtInput{
+			numCells:    numCells,
+			numRows:     numRows,
+			stepsPerRow: 8,
+			diffRateA:   0.005,
+			diffRateB:   0.2,
+			decayRateA:  0.05,
+			decayRateB:  0.08,
+			baseA:       0.05,
+			fluctuation: 0.1,
+			seed:        seed,
+			background:  m.NewColor(230, 210, 170),
+			pigment:     m.NewColor(90, 40, 10),
+		}
+	},
+	// travelling waves that cross and annihilate, drawing zigzags and chevrons
+	"waves": func(numCells, numRows int, seed int64) meinhardtInput {
+		return meinhardtInput{
+			numCells:    numCells,
+			numRows:     numRows,
+			stepsPerRow: 8,
+			diffRateA:   0.02,
+			decayRateA:  0.08,
+			decayRateB:  0.01,
+			baseA:       0.05,
+			fluctuation: 0.8,
+			seed:        seed,
+			background:  m.NewColor(230, 210, 170),
+			pigment:     m.NewColor(90, 40, 10),
+		}
+	},
+}
+
+// f in [0,1] blends from background to pigment
+func pigmentColor(f float64, background, pigment m.Color) color.RGBA {
+	f = math.Max(0, math.Min(1, f))
+	c := background.Times(float32(1 - f)).Add(pigment.Times(float32(f)))
+	return color.RGBA{c.R(), c.G(), c.B(), 255}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestMeinhardtLoop(t *testing.T) {
+	for i, tt := range []struct {
+		a, b, s      []float64
+		input        meinhardtInput
+		wantA, wantB []float64
+	}{
+		{
+			// reaction only: a uniform field stays uniform
+			a:     []float64{1, 1, 1},
+			b:     []float64{1, 1, 1},
+			s:     []float64{0.1, 0.1, 0.1},
+			input: meinhardtInput{diffRateA: 0.1, diffRateB: 0.1, decayRateA: 0.1, decayRateB: 0.2, baseA: 0.05, baseB: 0.01},
+			wantA: []float64{1.005, 1.005, 1.005},
+			wantB: []float64{0.91, 0.91, 0.91},
+		},
+		{
+			// diffusion only, wrapping around the ring
+			a:     []float64{1, 0, 0, 0},
+			b:     []float64{1, 1, 1, 2},
+			s:     []float64{0, 0, 0, 0},
+			input: meinhardtInput{diffRateA: 0.25, diffRateB: 0.5},
+			wantA: []float64{0.5, 0.25, 0, 0.25},
+			wantB: []float64{1.5, 1, 1.5, 1},
+		},
+	} {
+		gotA, gotB := meinhardtLoop(tt.a, tt.b, tt.s, tt.input)
+		for j := range tt.wantA {
+			if !approx(float32(gotA[j]), float32(tt.wantA[j])) || !approx(float32(gotB[j]), float32(tt.wantB[j])) {
+				t.Errorf("%d): got a=%v b=%v want a=%v b=%v", i, gotA, gotB, tt.wantA, tt.wantB)
+				break
+			}
+		}
+	}
+}
+
+func TestMeinhardt(t *testing.T) {
+	// without any reaction or diffusion the field never changes:
+	// no pigment, and no division by zero
+	uniform := meinhardtInput{
+		numCells:    16,
+		numRows:     10,
+		stepsPerRow: 1,
+		background:  m.NewColor(230, 210, 170),
+		pigment:     m.NewColor(90, 40, 10),
+	}
+	img := meinhardt(uniform)
+	if img.Bounds() != image.Rect(0, 0, 16, 10) {
+		t.Fatalf("expected 16x10 image, got %v", img.Bounds())
+	}
+	bg := pigmentColor(0, uniform.background, uniform.pigment)
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 16; x++ {
+			if img.At(x, y) != bg {
+				t.Fatalf("(%d,%d): expected background, got %v", x, y, img.At(x, y))
+			}
+		}
+	}
+
+	// stripes settle down, waves keep moving
+	for _, tt := range []struct {
+		pattern string
+		moving  bool
+	}{
+		{pattern: "stripes", moving: false},
+		{pattern: "waves", moving: true},
+	} {
+		input := pigmentPatterns[tt.pattern](100, 400, 1)
+		change := rowChange(meinhardt(input), 300)
+		if tt.moving != (change > 0.001) {
+			t.Errorf("%s: expected moving=%t, got average change %f over the last rows", tt.pattern, tt.moving, change)
+		}
+	}
+}
+
+// rowChange is how much pixels change from row to row from row y down,
+// on average, as a fraction of full intensity
+func rowChange(img image.Image, y int) float64 {
+	b := img.Bounds()
+	var sum float64
+	var n int
+	for ; y < b.Max.Y-1; y++ {
+		for x := 0; x < b.Max.X; x++ {
+			r1, _, _, _ := img.At(x, y).RGBA()
+			r2, _, _, _ := img.At(x, y+1).RGBA()
+			d := float64(r1) - float64(r2)
+			if d < 0 {
+				d = -d
+			}
+			sum += d / 0xffff
+			n++
+		}
+	}
+	return sum / float64(n)
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// poissonBox returns points inside the box from min to max, no two of them
+// closer than r, filling the box so that no more fit in. This is Bridson's
+// algorithm in 3D, trying k points around each point before giving up on it;
+// poisson does the same in 2D
+func poissonBox(min, max m.Vector, r float32, k int, rnd *rand.Rand) []m.Vector {
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(rand.Int63()))
+	}
+	inBox := func(p m.Vector) bool {
+		return p.X >= min.X && p.X <= max.X && p.Y >= min.Y && p.Y <= max.Y && p.Z >= min.Z && p.Z <= max.Z
+	}
+	grid := newPointGrid(r)
+	size := max.Sub(min)
+	first := min.Add(m.Vector{size.X * rnd.Float32(), size.Y * rnd.Float32(), size.Z * rnd.Float32()})
+	grid.add(first)
+	active := []int{0}
+	for len(active) > 0 {
+		a := rnd.Intn(len(active))
+		p := grid.points[active[a]]
+		found := false
+		for i := 0; i < k; i++ {
+			q := p.Add(randomDirection(rnd).Times(r * (1 + rnd.Float32())))
+			if !inBox(q) || len(grid.near(q, r)) > 0 {
+				continue
+			}
+			grid.add(q)
+			active = append(active, len(grid.points)-1)
+			found = true
+			break
+		}
+		if !found {
+			active[a] = active[len(active)-1]
+			active = active[:len(active)-1]
+		}
+	}
+	return grid.points
+}
+
+// surfacePoint is a point on a mesh and the normal of the triangle it is on
+type surfacePoint struct {
+	position, normal m.Vector
+}
+
+// poissonMesh returns points on the surface of a mesh, no two of them closer
+// than r, spread evenly over its area. Distance is measured straight through
+// space, or if geodesic is set along the surface as approximated by
+// arcDistance, which spaces points further apart around sharp bends.
+// Points are thrown at random and kept if they fit, until k throws have
+// been made for each r squared of area
+func poissonMesh(triangles []m.Triangle, r float32, k int, geodesic bool, rnd *rand.Rand) []surfacePoint {
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(rand.Int63()))
+	}
+	// pick triangles by area, by searching the running total of areas
+	cumulative := make([]float32, len(triangles))
+	normals := make([]m.Vector, len(triangles))
+	var area float32
+	for i, t := range triangles {
+		n := t.P1.Sub(t.P0).Cross(t.P2.Sub(t.P0))
+		area += n.Length() / 2.0
+		cumulative[i] = area
+		normals[i] = n.Normalize()
+	}
+	if area == 0 {
+		return nil
+	}
+
+	grid := newPointGrid(r)
+	samples := []surfacePoint{}
+	throws := int(float32(k) * area / (r * r))
+	for i := 0; i < throws; i++ {
+		a := rnd.Float32() * area
+		ti := sort.Search(len(cumulative), func(j int) bool { return cumulative[j] > a })
+		if ti == len(triangles) {
+			ti--
+		}
+		s := surfacePoint{position: randomInTriangle(triangles[ti], rnd), normal: normals[ti]}
+		fits := true
+		for _, j := range grid.near(s.position, r) {
+			if !geodesic {
+				fits = false
+				break
+			}
+			o := samples[j]
+			if arcDistance(s.position, s.normal, o.position, o.normal) < r {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			grid.add(s.position)
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+// arcDistance approximates the distance along a smooth surface between
+// p and q, with normals np and nq, by the length of a circular arc from p to q
+// that turns as far as the normals differ. Never less than the straight distance
+func arcDistance(p, np, q, nq m.Vector) float32 {
+	chord := q.Sub(p).Length()
+	cos := math.Max(-1, math.Min(1, float64(np.Dot(nq))))
+	half := math.Acos(cos) / 2.0
+	if half < 1e-4 {
+		return chord
+	}
+	return chord * float32(half/math.Sin(half))
+}
+
+// randomInTriangle returns a point on triangle t, uniformly distributed
+func randomInTriangle(t m.Triangle, rnd *rand.Rand) m.Vector {
+	u, v := rnd.Float32(), rnd.Float32()
+	// fold the far half of the parallelogram back onto the triangle
+	if u+v > 1 {
+		u, v = 1-u, 1-v
+	}
+	return t.P0.Add(t.P1.Sub(t.P0).Times(u)).Add(t.P2.Sub(t.P0).Times(v))
+}
+
+// randomDirection returns a unit vector, uniformly distributed over the sphere
+func randomDirection(rnd *rand.Rand) m.Vector {
+	z := 2*rnd.Float64() - 1
+	phi := 2 * math.Pi * rnd.Float64()
+	s := math.Sqrt(1 - z*z)
+	return m.Vector{float32(s * math.Cos(phi)), float32(s * math.Sin(phi)), float32(z)}
+}
+
+// pointGrid finds points near a position by hashing them into cubes
+type pointGrid struct {
+	size   float32
+	cells  map[[3]int][]int
+	points []m.Vector
+}
+
+func newPointGrid(size float32) *pointGrid {
+	return &pointGrid{size: size, cells: map[[3]int][]int{}}
+}
+
+func (g *pointGrid) cell(p m.Vector) [3]int {
+	return [3]int{
+		int(math.Floor(float64(p.X / g.size))),
+		int(math.Floor(float64(p.Y / g.size))),
+		int(math.Floor(float64(p.Z / g.size))),
+	}
+}
+
+func (g *pointGrid) add(p m.Vector) {
+	c := g.cell(p)
+	g.cells[c] = append(g.cells[c], len(g.points))
+	g.points = append(g.points, p)
+}
+
+// near returns the indices of points closer to p than r, which can be
+// at most the size of the grid cubes
+func (g *pointGrid) near(p m.Vector, r float32) []int {
+	c := g.cell(p)
+	found := []int{}
+	for x := c[0] - 1; x <= c[0]+1; x++ {
+		for y := c[1] - 1; y <= c[1]+1; y++ {
+			for z := c[2] - 1; z <= c[2]+1; z++ {
+				for _, i := range g.cells[[3]int{x, y, z}] {
+					if g.points[i].Sub(p).Length() < r {
+						found = append(found, i)
+					}
+				}
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestPoissonBox(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	min, max := m.Vector{-1, 0, 2}, m.Vector{3, 2, 5}
+	var r float32 = 0.3
+	points := poissonBox(min, max, r, 30, rnd)
+	for i, p := range points {
+		if p.X < min.X || p.X > max.X || p.Y < min.Y || p.Y > max.Y || p.Z < min.Z || p.Z > max.Z {
+			t.Fatalf("%d): point %v outside the box", i, p)
+		}
+		for j := i + 1; j < len(points); j++ {
+			if d := p.Sub(points[j]).Length(); d < r {
+				t.Fatalf("%d, %d): points %f apart", i, j, d)
+			}
+		}
+	}
+	// no gaps large enough for another point
+	grid := newPointGrid(2 * r)
+	for _, p := range points {
+		grid.add(p)
+	}
+	for i := 0; i < 1000; i++ {
+		p := min.Add(m.Vector{4 * rnd.Float32(), 2 * rnd.Float32(), 3 * rnd.Float32()})
+		if len(grid.near(p, 2*r)) == 0 {
+			t.Fatalf("no point near %v", p)
+		}
+	}
+}
+
+func TestPoissonMesh(t *testing.T) {
+	cube := m.NewCuboid(m.NewAABB(m.Vector{-1, -1, -1}, m.Vector{1, 1, 1}), &m.DiffuseMaterial{}).Tesselate()
+	var r float32 = 0.2
+	for _, geodesic := range []bool{false, true} {
+		rnd := rand.New(rand.NewSource(1))
+		points := poissonMesh(cube, r, 30, geodesic, rnd)
+		// evenly spread: about one point per r squared of the area of 24
+		if len(points) < 200 || len(points) > 600 {
+			t.Errorf("geodesic %v: got %d points", geodesic, len(points))
+		}
+		for i, s := range points {
+			p := s.position
+			onFace := false
+			for _, c := range []float32{p.X, p.Y, p.Z} {
+				if c < -1-1e-5 || c > 1+1e-5 {
+					t.Fatalf("geodesic %v: point %v off the cube", geodesic, p)
+				}
+				if math.Abs(math.Abs(float64(c))-1) < 1e-5 {
+					onFace = true
+				}
+			}
+			if !onFace {
+				t.Fatalf("geodesic %v: point %v inside the cube", geodesic, p)
+			}
+			// normals of the cube point out of its faces
+			if s.normal.Dot(p) < 0.99 {
+				t.Errorf("geodesic %v: normal %v at %v", geodesic, s.normal, p)
+			}
+			for j := i + 1; j < len(points); j++ {
+				o := points[j]
+				d := p.Sub(o.position).Length()
+				if geodesic {
+					d = arcDistance(p, s.normal, o.position, o.normal)
+				}
+				if d < r {
+					t.Fatalf("geodesic %v: %d, %d): points %f apart", geodesic, i, j, d)
+				}
+			}
+		}
+	}
+}
+
+func TestArcDistance(t *testing.T) {
+	for i, tt := range []struct {
+		p, np, q, nq m.Vector
+		want         float32
+	}{
+		// a flat surface
+		{p: m.Vector{0, 0, 0}, np: ey, q: m.Vector{3, 0, 4}, nq: ey, want: 5},
+		// a quarter of a unit circle
+		{p: ex, np: ex, q: ey, nq: ey, want: math.Pi / 2},
+		// half of it
+		{p: ex, np: ex, q: ex.Times(-1), nq: ex.Times(-1), want: math.Pi},
+	} {
+		if got := arcDistance(tt.p, tt.np, tt.q, tt.nq); !approx(got, tt.want) {
+			t.Errorf("%d): got %f want %f", i, got, tt.want)
+		}
+	}
+}
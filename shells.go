@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"math"
 
 	m "github.com/deosjr/GRayT/src/model"
@@ -30,6 +31,37 @@ import (
 // spire: Raup's T
 // If the acute angle between the line P1P2 and the horizontal is alpha, then tan alpha = T.
 func generateShell(flare, verm, spire float64, numWindings int) m.Object {
+	helix, radius := shellCurves(flare, verm, spire)
+	generatingCurve := gen.NewRadialCircle(radius, shellNumPoints)
+	numSteps := 64 * numWindings
+	mat := m.NewDiffuseMaterial(m.NewConstantTexture(m.NewColor(200, 100, 0)))
+
+	po := gen.NewParametricObject(helix, generatingCurve, numSteps, shellStepSize, mat)
+	return po.Build()
+}
+
+const (
+	// number of points on the generating curve
+	shellNumPoints = 100
+	// increase in t between consecutive generating curves, 64 steps per winding
+	shellStepSize = math.Pi / 32.0
+)
+
+// the helix and generating curve types in gen are unexported,
+// so we describe them by the methods we need
+type shellHelix interface {
+	Function() gen.ParametricFunction
+	Derivative() gen.ParametricFunction
+	SecondDerivative() gen.ParametricFunction
+}
+
+type shellRadial interface {
+	Points(p, normal, binormal m.Vector, t float64) []m.Vector
+}
+
+// shellCurves returns the helix the shell coils around and the radius of the
+// generating circle that is swept along it. See generateShell for the meaning of the parameters.
+func shellCurves(flare, verm, spire float64) (shellHelix, func(t float64) float32) {
 	aFunc := func(t float64) float64 {
 		return math.Pow(flare, (t/(2*math.Pi))) - 1
 	}
@@ -61,13 +93,232 @@ func generateShell(flare, verm, spire float64, numWindings int) m.Object {
 	// a - Da = Dr + r = r(D + 1)
 	// r = (a - Da) / (D + 1) = -(D - 1) * a / (D + 1)
 
-	generatingCurve := gen.NewRadialCircle(func(t float64) float32 {
+	radius := func(t float64) float32 {
 		return float32((-verm + 2) * aFunc(t) / (verm + 1))
-	}, 100)
+	}
+	return helix, radius
+}
+
+// shellFrame returns the point on the helix at t and the normal and binormal
+// of its Frenet-Serret frame, as used by gen.NewParametricObject
+func shellFrame(helix shellHelix, t float64) (p, normal, binormal m.Vector) {
+	p = helix.Function().Vector(t)
+	tangent := helix.Derivative().Vector(t).Normalize()
+	secondDeriv := helix.SecondDerivative().Vector(t)
+	normal = secondDeriv.Sub(tangent.Times(secondDeriv.Dot(tangent)))
+	binormal = tangent.Cross(normal)
+	return p, normal, binormal
+}
+
+// generateTexturedShell builds the same surface as generateShell, but as a mesh
+// with uv coordinates so it can be textured: u runs around the generating curve
+// and v runs along the growth of the shell, from the apex (v=1) to the aperture (v=0)
+// The mesh is centered on the origin and scaled to fit a cube of the given size.
+func generateTexturedShell(flare, verm, spire float64, numWindings int, size float32, tex m.Texture) m.Object {
+	helix, radius := shellCurves(flare, verm, spire)
+	generatingCurve := gen.NewRadialCircle(radius, shellNumPoints)
 	numSteps := 64 * numWindings
-	stepSize := math.Pi / 32.0
+
+	// the first point of each generating curve is repeated at the end
+	// so that u can wrap around from 0 to 1 without a seam
+	rowLen := shellNumPoints + 1
+	vertices := make([]m.Vector, 0, numSteps*rowLen)
+	uvs := make([]m.Vector, 0, numSteps*rowLen)
+	for i := 0; i < numSteps; i++ {
+		t := float64(i) * shellStepSize
+		p, normal, binormal := shellFrame(helix, t)
+		points := generatingCurve.Points(p, normal, binormal, t)
+		points = append(points, points[0])
+		v := 1 - float32(i)/float32(numSteps-1)
+		for j, point := range points {
+			vertices = append(vertices, point)
+			uvs = append(uvs, m.Vector{float32(j) / float32(shellNumPoints), v, 0})
+		}
+	}
+
+	// shells grow exponentially, so scale the points themselves:
+	// see transformObject on why not to use a scaled SharedObject
+	vertices = fitPoints(vertices, size)
+
+	// same triangle winding as gen.JoinPoints
+	faces := []m.Face{}
+	for i := 0; i < numSteps-1; i++ {
+		for j := 0; j < shellNumPoints; j++ {
+			c1j := int64(i*rowLen + j)
+			c2j := int64((i+1)*rowLen + j)
+			faces = append(faces, m.NewFace(c1j, c1j+1, c2j), m.NewFace(c2j, c1j+1, c2j+1))
+		}
+	}
+
+	mat := m.NewDiffuseMaterial(tex)
+	mesh := m.NewTriangleMesh(vertices, faces, mat)
+	uvMap := make(map[int64]m.Vector, len(uvs))
+	for i, uv := range uvs {
+		uvMap[int64(i)] = uv
+	}
+	mesh.(*m.TriangleMesh).UV = uvMap
+	return mesh
+}
+
+// generateSolidShell gives the shell wall a thickness, as a fraction of the
+// radius of the generating curve: the inner surface has radius (1-thickness)*r.
+// Inner and outer surface are joined at the aperture lip and at the apex,
+// resulting in a watertight mesh that can be exported using SaveObj.
+// Thickness has to be strictly between 0 and 1: at 0 the surfaces coincide,
+// at 1 or more the inner surface collapses onto the helix or turns inside out
+func generateSolidShell(flare, verm, spire float64, numWindings int, thickness float64) (m.Object, error) {
+	if thickness <= 0 || thickness >= 1 {
+		return nil, fmt.Errorf("shell thickness %f not between 0 and 1", thickness)
+	}
+	helix, radius := shellCurves(flare, verm, spire)
+	outerCurve := gen.NewRadialCircle(radius, shellNumPoints)
+	innerCurve := gen.NewRadialCircle(func(t float64) float32 {
+		return float32(1-thickness) * radius(t)
+	}, shellNumPoints)
+	numSteps := 64 * numWindings
+
+	// at t=0 the radius is 0 and the frenet frame is undefined,
+	// so we start one step in and close off the apex instead
+	outer := make([][]m.Vector, numSteps-1)
+	inner := make([][]m.Vector, numSteps-1)
+	for i := 1; i < numSteps; i++ {
+		t := float64(i) * shellStepSize
+		p, normal, binormal := shellFrame(helix, t)
+		outer[i-1] = outerCurve.Points(p, normal, binormal, t)
+		// inner surface is reversed so that its normals face into the cavity
+		inner[len(inner)-i] = innerCurve.Points(p, normal, binormal, t)
+	}
+
 	mat := m.NewDiffuseMaterial(m.NewConstantTexture(m.NewColor(200, 100, 0)))
+	triangles := joinRings(outer, mat)
+	triangles = append(triangles, joinRings(inner, mat)...)
+	// aperture lip and apex
+	triangles = append(triangles, joinRings([][]m.Vector{outer[len(outer)-1], inner[0]}, mat)...)
+	triangles = append(triangles, joinRings([][]m.Vector{inner[len(inner)-1], outer[0]}, mat)...)
+	return m.NewTriangleComplexObject(triangles), nil
+}
 
-	po := gen.NewParametricObject(helix, generatingCurve, numSteps, stepSize, mat)
-	return po.Build()
+// joinRings is gen.JoinPoints with consistent winding: gen.JoinPoints flips
+// the two triangles closing the seam between last and first point of each ring,
+// which is fine for rendering but not when the mesh has to be watertight.
+// assumes each ring has the same number of points
+func joinRings(rings [][]m.Vector, mat m.Material) []m.Triangle {
+	triangles := []m.Triangle{}
+	for i := 0; i < len(rings)-1; i++ {
+		c1, c2 := rings[i], rings[i+1]
+		n := len(c1)
+		for j := 0; j < n; j++ {
+			next := (j + 1) % n
+			triangles = append(triangles,
+				m.NewTriangle(c1[j], c1[next], c2[j], mat),
+				m.NewTriangle(c2[j], c1[next], c2[next], mat))
+		}
+	}
+	return triangles
+}
+
+// fitPoints centers points on the origin and scales them uniformly
+// so that their bounding box fits in a cube of the given size
+func fitPoints(points []m.Vector, size float32) []m.Vector {
+	b := m.NewAABB(points[0], points[0])
+	for _, p := range points[1:] {
+		b = b.AddPoint(p)
+	}
+	extent := m.VectorFromTo(b.Pmin, b.Pmax)
+	maxExtent := float32(math.Max(float64(extent.X), math.Max(float64(extent.Y), float64(extent.Z))))
+	transform := m.ScaleUniform(size / maxExtent).Mul(m.Translate(b.Centroid().Times(-1)))
+	fitted := make([]m.Vector, len(points))
+	for i, p := range points {
+		fitted[i] = transform.Point(p)
+	}
+	return fitted
+}
+
+// paramRange samples n values evenly from min to max inclusive
+type paramRange struct {
+	min, max float64
+	n        int
+}
+
+func (r paramRange) values() []float64 {
+	if r.n <= 1 {
+		return []float64{r.min}
+	}
+	values := make([]float64, r.n)
+	step := (r.max - r.min) / float64(r.n-1)
+	for i := 0; i < r.n; i++ {
+		values[i] = r.min + float64(i)*step
+	}
+	return values
+}
+
+// Raup's cube: a grid of shells sampled across W, D and T
+// flare runs along the x-axis, spire along the y-axis and verm along the z-axis
+type morphospaceParams struct {
+	flare       paramRange
+	verm        paramRange
+	spire       paramRange
+	numWindings int
+	// each shell is scaled to fit in a cube of cellSize,
+	// cells are spaced cellSize*spacing apart
+	cellSize float32
+	spacing  float32
+}
+
+// morphospaceCell is one shell in the grid: its index along each axis,
+// its parameters and the center of its cell
+type morphospaceCell struct {
+	x, y, z            int
+	flare, verm, spire float64
+	center             m.Vector
+}
+
+// cells lists every cell in the grid, which is centered on the origin
+func (params morphospaceParams) cells() []morphospaceCell {
+	flares, verms, spires := params.flare.values(), params.verm.values(), params.spire.values()
+	step := params.cellSize * params.spacing
+	offset := m.Vector{
+		X: -step * float32(len(flares)-1) / 2.0,
+		Y: -step * float32(len(spires)-1) / 2.0,
+		Z: -step * float32(len(verms)-1) / 2.0,
+	}
+	cells := []morphospaceCell{}
+	for x, flare := range flares {
+		for y, spire := range spires {
+			for z, verm := range verms {
+				center := m.Vector{X: float32(x), Y: float32(y), Z: float32(z)}.Times(step).Add(offset)
+				cells = append(cells, morphospaceCell{x, y, z, flare, verm, spire, center})
+			}
+		}
+	}
+	return cells
+}
+
+// morphospace returns all shells in the grid, each scaled to cellSize
+// and placed at its cell position
+func morphospace(params morphospaceParams) m.Object {
+	shells := []m.Object{}
+	for _, c := range params.cells() {
+		shell := generateShell(c.flare, c.verm, c.spire, params.numWindings)
+		// scale to fit the cell and center on origin first
+		b := shell.Bound(m.ScaleUniform(1.0))
+		extent := m.VectorFromTo(b.Pmin, b.Pmax)
+		maxExtent := float32(math.Max(float64(extent.X), math.Max(float64(extent.Y), float64(extent.Z))))
+		transform := m.Translate(c.center)
+		transform = transform.Mul(m.ScaleUniform(params.cellSize / maxExtent))
+		transform = transform.Mul(m.Translate(b.Centroid().Times(-1)))
+		shells = append(shells, transformObject(shell, transform))
+	}
+	return m.NewComplexObject(shells)
+}
+
+// legend lists the W, D, T values of each cell in the grid, indexed by
+// cell position as (x, y, z). The render itself carries no labels,
+// so this is printed alongside it as the key to the picture
+func (params morphospaceParams) legend() string {
+	s := ""
+	for _, c := range params.cells() {
+		s += fmt.Sprintf("(%d,%d,%d): W=%.2f D=%.2f T=%.2f\n", c.x, c.y, c.z, c.flare, c.verm, c.spire)
+	}
+	return s
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestSolidShellWatertight(t *testing.T) {
+	for i, tt := range []struct {
+		flare, verm, spire float64
+		numWindings        int
+		thickness          float64
+	}{
+		{flare: 2.0, verm: 0.1, spire: 2.5, numWindings: 3, thickness: 0.1},
+		{flare: 3.0, verm: 0.5, spire: 0.5, numWindings: 2, thickness: 0.5},
+	} {
+		shell, err := generateSolidShell(tt.flare, tt.verm, tt.spire, tt.numWindings, tt.thickness)
+		if err != nil {
+			t.Fatalf("%d): %v", i, err)
+		}
+		if err := watertight(trianglesFromObject(shell)); err != nil {
+			t.Errorf("%d): %v", i, err)
+		}
+	}
+}
+
+func TestSolidShellThickness(t *testing.T) {
+	for _, thickness := range []float64{-0.1, 0, 1, 1.5} {
+		if _, err := generateSolidShell(2.0, 0.1, 2.5, 1, thickness); err == nil {
+			t.Errorf("thickness %f: expected error", thickness)
+		}
+	}
+}
+
+func TestParamRangeValues(t *testing.T) {
+	for i, tt := range []struct {
+		r    paramRange
+		want []float64
+	}{
+		{r: paramRange{min: 1, max: 3, n: 3}, want: []float64{1, 2, 3}},
+		{r: paramRange{min: 0, max: 0.6, n: 4}, want: []float64{0, 0.2, 0.4, 0.6}},
+		{r: paramRange{min: 2, max: 5, n: 1}, want: []float64{2}},
+		{r: paramRange{min: 2, max: 5, n: 0}, want: []float64{2}},
+	} {
+		got := tt.r.values()
+		if len(got) != len(tt.want) {
+			t.Errorf("%d): got %v want %v", i, got, tt.want)
+			continue
+		}
+		for j := range got {
+			if !approx(float32(got[j]), float32(tt.want[j])) {
+				t.Errorf("%d): got %v want %v", i, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestMorphospaceCells(t *testing.T) {
+	params := morphospaceParams{
+		flare:    paramRange{min: 1.5, max: 4.0, n: 4},
+		verm:     paramRange{min: 0.0, max: 0.6, n: 3},
+		spire:    paramRange{min: 0.5, max: 4.0, n: 2},
+		cellSize: 1.0,
+		spacing:  1.5,
+	}
+	cells := params.cells()
+	if len(cells) != 4*3*2 {
+		t.Fatalf("expected %d cells, got %d", 4*3*2, len(cells))
+	}
+	var sum m.Vector
+	for _, c := range cells {
+		sum = sum.Add(c.center)
+		// flare along x, spire along y, verm along z, spaced 1.5 apart
+		want := m.Vector{X: 1.5 * (float32(c.x) - 1.5), Y: 1.5 * (float32(c.y) - 0.5), Z: 1.5 * (float32(c.z) - 1)}
+		if !compareVector(c.center, want) {
+			t.Errorf("cell (%d,%d,%d): got center %v want %v", c.x, c.y, c.z, c.center, want)
+		}
+		if !approx(float32(c.flare), float32(params.flare.values()[c.x])) ||
+			!approx(float32(c.spire), float32(params.spire.values()[c.y])) ||
+			!approx(float32(c.verm), float32(params.verm.values()[c.z])) {
+			t.Errorf("cell (%d,%d,%d): wrong parameters %v", c.x, c.y, c.z, c)
+		}
+	}
+	if !compareVector(sum, m.Vector{}) {
+		t.Errorf("expected grid centered on origin, got sum of centers %v", sum)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+type vaultType int
+
+const (
+	// four cells: two transverse ribs, two wall ribs and two diagonal ribs
+	quadripartiteVault vaultType = iota
+	// six cells: an extra transverse rib through the center of the bay
+	sexpartiteVault
+)
+
+// vaultParams describe a rib vault over a rectangular bay in the XZ plane,
+// centered on x=0 and z=0 and springing from y=0
+type vaultParams struct {
+	material  m.Material
+	vaultType vaultType
+	// size of the bay along the x-axis and the z-axis
+	width, length float32
+	// height of the crown above the springing line. All ribs are pointed arches
+	// reaching this height; it is raised to half the diagonal if lower,
+	// since the diagonal ribs cannot be flatter than a rounded arch
+	rise float32
+	// width of the ribs and how far they project below the webs
+	ribWidth, ribDepth float32
+	// number of points on each half of an arch, at least 3
+	numPoints int
+}
+
+// validate checks that the bay has an area and the ribs have a shape
+func (params vaultParams) validate() error {
+	if params.width <= 0 || params.length <= 0 {
+		return fmt.Errorf("vault bay %f by %f has no area", params.width, params.length)
+	}
+	if params.numPoints < 3 {
+		return fmt.Errorf("vault needs at least 3 points on each half arch, got %d", params.numPoints)
+	}
+	return nil
+}
+
+// ribVault returns the ribs and the webs between them. The bay is split into
+// cells around its center, each bounded by an arch between two springers and
+// by the two half ribs running from those springers to the center.
+// Webs are laid in horizontal courses between ribs, as they would be built.
+func ribVault(params vaultParams) (m.Object, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	w, l := params.width/2.0, params.length/2.0
+	springers := []m.Vector{{-w, 0, -l}, {w, 0, -l}, {w, 0, l}, {-w, 0, l}}
+	if params.vaultType == sexpartiteVault {
+		springers = []m.Vector{{-w, 0, -l}, {w, 0, -l}, {w, 0, 0}, {w, 0, l}, {-w, 0, l}, {-w, 0, 0}}
+	}
+	rise := params.rise
+	if diagonal := 2 * float32(math.Sqrt(float64(w*w+l*l))); rise < diagonal/2.0 {
+		rise = diagonal / 2.0
+	}
+	center := m.Vector{0, 0, 0}
+
+	triangles := []m.Triangle{}
+	for i, p := range springers {
+		q := springers[(i+1)%len(springers)]
+		boundary := vaultArch(p, q, rise, params.numPoints)
+		ribP := vaultArch(p, p.Add(m.VectorFromTo(p, center).Times(2)), rise, params.numPoints).left
+		ribQ := vaultArch(q, q.Add(m.VectorFromTo(q, center).Times(2)), rise, params.numPoints).left
+		triangles = append(triangles, rib(params, append(boundary.left, boundary.right[1:]...))...)
+		triangles = append(triangles, rib(params, ribP)...)
+		triangles = append(triangles, web(params.material, boundary.left, ribP, rise, params.numPoints)...)
+		triangles = append(triangles, web(params.material, ribQ, reversePoints(boundary.right), rise, params.numPoints)...)
+	}
+	return m.NewTriangleComplexObject(triangles), nil
+}
+
+// vaultArch returns a pointed arch standing on p and q, both at y=0,
+// with its excess chosen so that it reaches height rise: see createArch
+func vaultArch(p, q m.Vector, rise float32, numPoints int) arch {
+	span := m.VectorFromTo(p, q).Length()
+	excess := (rise/span)*(rise/span) + 0.25
+	a := pointedArch{excess}.arch(m.Vector{0, 0, 0}, m.Vector{span, 0, 0}, 0, numPoints)
+	dir := m.VectorFromTo(p, q).Normalize()
+	toPlane := func(points []m.Vector) []m.Vector {
+		placed := make([]m.Vector, len(points))
+		for i, v := range points {
+			placed[i] = p.Add(dir.Times(v.X)).Add(ey.Times(v.Y))
+		}
+		return placed
+	}
+	return arch{left: toPlane(a.left), right: toPlane(a.right)}
+}
+
+// rib returns a rib of rectangular section hanging below the curve,
+// which needs at least 3 points to tell up from down
+func rib(params vaultParams, curve []m.Vector) []m.Triangle {
+	hw := params.ribWidth / 2.0
+	side := m.VectorFromTo(curve[0], curve[len(curve)-1]).Cross(ey).Normalize()
+	rings := make([][]m.Vector, len(curve))
+	var sign float32 = 1
+	for i, p := range curve {
+		prev, next := curve[maxInt(i-1, 0)], curve[minInt(i+1, len(curve)-1)]
+		normal := side.Cross(m.VectorFromTo(prev, next)).Normalize()
+		if i == 0 {
+			// normal should point up at the crown, and the curve
+			// is planar so the sign is the same everywhere
+			mid := len(curve) / 2
+			tangent := m.VectorFromTo(curve[mid-1], curve[mid+1])
+			if side.Cross(tangent).Y < 0 {
+				sign = -1
+			}
+		}
+		down := normal.Times(-sign * params.ribDepth)
+		rings[i] = []m.Vector{
+			p.Add(side.Times(hw)),
+			p.Add(side.Times(hw)).Add(down),
+			p.Sub(side.Times(hw)).Add(down),
+			p.Sub(side.Times(hw)),
+		}
+	}
+	return joinRings(rings, params.material)
+}
+
+// web returns the surface between two curves rising from the same springer
+// to the same height, as horizontal courses from one curve to the other
+func web(mat m.Material, c1, c2 []m.Vector, rise float32, numCourses int) []m.Triangle {
+	triangles := []m.Triangle{}
+	prev1, prev2 := c1[0], c2[0]
+	for k := 1; k <= numCourses; k++ {
+		// courses are denser near the crown where the curves flatten
+		y := rise * float32(math.Sin(float64(k)/float64(numCourses)*math.Pi/2.0))
+		p1, p2 := pointAtHeight(c1, y), pointAtHeight(c2, y)
+		if k > 1 {
+			triangles = append(triangles, m.NewTriangle(p2, prev2, prev1, mat))
+		}
+		triangles = append(triangles, m.NewTriangle(p1, p2, prev1, mat))
+		prev1, prev2 = p1, p2
+	}
+	return triangles
+}
+
+// pointAtHeight interpolates a curve with increasing y at height y
+func pointAtHeight(curve []m.Vector, y float32) m.Vector {
+	for i := 0; i < len(curve)-1; i++ {
+		p, q := curve[i], curve[i+1]
+		if y > q.Y {
+			continue
+		}
+		if q.Y == p.Y {
+			return q
+		}
+		return p.Add(m.VectorFromTo(p, q).Times((y - p.Y) / (q.Y - p.Y)))
+	}
+	return curve[len(curve)-1]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestRibVault(t *testing.T) {
+	for i, tt := range []struct {
+		vaultType vaultType
+		numPoints int
+	}{
+		{vaultType: quadripartiteVault, numPoints: 3},
+		{vaultType: quadripartiteVault, numPoints: 10},
+		{vaultType: sexpartiteVault, numPoints: 10},
+	} {
+		params := vaultParams{
+			material:  &m.DiffuseMaterial{},
+			vaultType: tt.vaultType,
+			width:     8,
+			length:    4,
+			rise:      5,
+			ribWidth:  0.25,
+			ribDepth:  0.25,
+			numPoints: tt.numPoints,
+		}
+		vault, err := ribVault(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := vault.Bound(m.ScaleUniform(1.0))
+		if b.Pmin.X < -4.2 || b.Pmax.X > 4.2 || b.Pmin.Z < -2.2 || b.Pmax.Z > 2.2 || !approx(b.Pmax.Y, 5) {
+			t.Errorf("%d): got bounds %v %v", i, b.Pmin, b.Pmax)
+		}
+		// looking up from the nave, ribs and webs all face down at us
+		// and cover the bay without gaps. Rays close to the walls could
+		// enter the open ends of the ribs at the springers, so we stay clear
+		for x := float32(-3.6); x < 3.7; x += 0.3 {
+			for z := float32(-1.8); z < 1.9; z += 0.3 {
+				ray := m.NewRay(m.Vector{x, -1, z}, ey)
+				si, hit := vault.Intersect(ray)
+				if !hit {
+					t.Errorf("%d): ray up at %f %f missed the vault", i, x, z)
+					continue
+				}
+				if si.GetNormal().Dot(ray.Direction) >= 0 {
+					t.Errorf("%d): surface above %f %f faces up, normal %v", i, x, z, si.GetNormal())
+				}
+			}
+		}
+	}
+}
+
+func TestVaultValidate(t *testing.T) {
+	for i, params := range []vaultParams{
+		{width: 8, length: 4, numPoints: 2},
+		{width: 0, length: 4, numPoints: 10},
+		{width: 8, length: -1, numPoints: 10},
+	} {
+		params.material = &m.DiffuseMaterial{}
+		if _, err := ribVault(params); err == nil {
+			t.Errorf("%d): expected error", i)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+// voronoiCells returns the voronoi cell of each site in the XY plane,
+// clipped by the box from min to max, as a polygon of its corners
+// in counter-clockwise order. Cells are in the same order as the sites;
+// of repeated sites only one has a cell, and a site outside the box has none.
+// There are no cells at all without a triangulation of the sites,
+// see triangulate
+func voronoiCells(sites []m.Vector, min, max m.Vector) [][]m.Vector {
+	return diagramCells(sites, nil, min, max)
+}
+
+// powerCells returns the cells of the power diagram of weighted sites, as
+// voronoiCells. A point belongs to the site with the least power distance
+// |p-s|^2 - w to it, so cells of sites with larger weights grow at the
+// expense of their neighbours. Sites can lose their cell altogether
+func powerCells(sites []m.Vector, weights []float32, min, max m.Vector) [][]m.Vector {
+	return diagramCells(sites, weights, min, max)
+}
+
+// diagramCells returns the cells of the diagram dual to the delaunay
+// triangulation of the sites, weighted as in powerCells if weights is not nil.
+// Each cell is the box clipped by the bisectors between its site and its
+// neighbours in the triangulation. Weighted cells can border sites that are
+// not Delaunay neighbours, so they are clipped further by every site close
+// enough to cut them, see powerReach
+func diagramCells(sites []m.Vector, weights []float32, min, max m.Vector) [][]m.Vector {
+	box := []m.Vector{{min.X, min.Y, 0}, {max.X, min.Y, 0}, {max.X, max.Y, 0}, {min.X, max.Y, 0}}
+	cells := make([][]m.Vector, len(sites))
+	d, err := triangulate(sites)
+	if err != nil {
+		return cells
+	}
+	neighbours := delaunayNeighbours(d, len(sites))
+	weight := func(i int) float32 {
+		if weights == nil {
+			return 0
+		}
+		return weights[i]
+	}
+	bisector := func(i, n int) halfPlane {
+		s, t := sites[i], sites[n]
+		normal := m.Vector{t.X - s.X, t.Y - s.Y, 0}
+		mid := m.Vector{(s.X + t.X) / 2.0, (s.Y + t.Y) / 2.0, 0}
+		return halfPlane{normal: normal, d: normal.Dot(mid) + (weight(i)-weight(n))/2.0}
+	}
+	var grid siteGrid
+	maxWeight := float32(math.Inf(-1))
+	if weights != nil {
+		grid = newSiteGrid(sites, neighbours)
+		for i, ns := range neighbours {
+			if len(ns) > 0 && weights[i] > maxWeight {
+				maxWeight = weights[i]
+			}
+		}
+	}
+	for i, ns := range neighbours {
+		if len(ns) == 0 {
+			continue
+		}
+		planes := make([]halfPlane, len(ns))
+		for j, n := range ns {
+			planes[j] = bisector(i, n)
+		}
+		cell := clipCell(box, planes)
+		if weights == nil || cell == nil {
+			cells[i] = cell
+			continue
+		}
+		seen := map[int]bool{i: true}
+		for _, n := range ns {
+			seen[n] = true
+		}
+		planes = planes[:0]
+		for _, n := range grid.within(sites[i], powerReach(sites[i], cell, maxWeight-weights[i])) {
+			if !seen[n] {
+				planes = append(planes, bisector(i, n))
+			}
+		}
+		cells[i] = clipCell(cell, planes)
+	}
+	return cells
+}
+
+// powerReach returns how far from site s another site can be and still cut
+// its cell, which lies within polygon, when the other site weighs at most
+// excess more than s. A point p of the cell goes to site t only if
+// |p-t|^2 < |p-s|^2 + excess, so t is within r + sqrt(r^2 + excess) of s,
+// with r the distance from s to the furthest corner of the polygon
+func powerReach(s m.Vector, polygon []m.Vector, excess float32) float32 {
+	var r2 float32
+	for _, p := range polygon {
+		d := m.Vector{p.X - s.X, p.Y - s.Y, 0}
+		if l := d.Dot(d); l > r2 {
+			r2 = l
+		}
+	}
+	if r2+excess <= 0 {
+		return 0
+	}
+	return float32(math.Sqrt(float64(r2))) + float32(math.Sqrt(float64(r2+excess)))
+}
+
+// siteGrid buckets the sites of a triangulation in the XY plane by square,
+// to find the sites near a point without going through all of them
+type siteGrid struct {
+	points  []m.Vector
+	min     m.Vector
+	size    float32
+	nx, ny  int
+	buckets [][]int
+}
+
+// newSiteGrid buckets the sites with neighbours, which leaves out
+// repeated sites, in squares of about one site each. The sites span
+// an area, as they have a triangulation
+func newSiteGrid(points []m.Vector, neighbours [][]int) siteGrid {
+	var min, max m.Vector
+	n := 0
+	for i, p := range points {
+		if len(neighbours[i]) == 0 {
+			continue
+		}
+		if n == 0 {
+			min, max = p, p
+		}
+		min = m.Vector{float32(math.Min(float64(min.X), float64(p.X))), float32(math.Min(float64(min.Y), float64(p.Y))), 0}
+		max = m.Vector{float32(math.Max(float64(max.X), float64(p.X))), float32(math.Max(float64(max.Y), float64(p.Y))), 0}
+		n++
+	}
+	size := float32(math.Sqrt(float64((max.X - min.X) * (max.Y - min.Y) / float32(n))))
+	g := siteGrid{points: points, min: min, size: size}
+	g.nx, g.ny = g.square(max.X-min.X)+1, g.square(max.Y-min.Y)+1
+	g.buckets = make([][]int, g.nx*g.ny)
+	for i, p := range points {
+		if len(neighbours[i]) == 0 {
+			continue
+		}
+		b := g.square(p.Y-min.Y)*g.nx + g.square(p.X-min.X)
+		g.buckets[b] = append(g.buckets[b], i)
+	}
+	return g
+}
+
+func (g siteGrid) square(d float32) int {
+	return int(d / g.size)
+}
+
+// within returns the sites less than r away from p
+func (g siteGrid) within(p m.Vector, r float32) []int {
+	clamp := func(v, n int) int {
+		if v < 0 {
+			return 0
+		}
+		if v >= n {
+			return n - 1
+		}
+		return v
+	}
+	x0, x1 := clamp(g.square(p.X-r-g.min.X), g.nx), clamp(g.square(p.X+r-g.min.X), g.nx)
+	y0, y1 := clamp(g.square(p.Y-r-g.min.Y), g.ny), clamp(g.square(p.Y+r-g.min.Y), g.ny)
+	sites := []int{}
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			for _, i := range g.buckets[y*g.nx+x] {
+				if d := g.points[i].Sub(p); d.X*d.X+d.Y*d.Y < r*r {
+					sites = append(sites, i)
+				}
+			}
+		}
+	}
+	return sites
+}
+
+// halfPlane holds the points p in the XY plane for which normal.p <= d
+type halfPlane struct {
+	normal m.Vector
+	d      float32
+}
+
+// clipCell clips a convex counter-clockwise polygon by each half plane,
+// returning nil if nothing is left of it
+func clipCell(polygon []m.Vector, planes []halfPlane) []m.Vector {
+	for _, h := range planes {
+		clipped := make([]m.Vector, 0, len(polygon)+1)
+		for i, p := range polygon {
+			q := polygon[(i+1)%len(polygon)]
+			dp := h.normal.Dot(p) - h.d
+			dq := h.normal.Dot(q) - h.d
+			if dp <= 0 {
+				clipped = append(clipped, p)
+			}
+			if (dp < 0 && dq > 0) || (dp > 0 && dq < 0) {
+				clipped = append(clipped, p.Add(q.Sub(p).Times(dp/(dp-dq))))
+			}
+		}
+		if len(clipped) < 3 {
+			return nil
+		}
+		polygon = clipped
+	}
+	// drop corners too close to the one before, where a bisector
+	// passed through a corner or the edge of the box
+	cell := []m.Vector{}
+	for i, p := range polygon {
+		if p.Sub(polygon[(i+len(polygon)-1)%len(polygon)]).Length() > 1e-6 {
+			cell = append(cell, p)
+		}
+	}
+	if len(cell) < 3 {
+		return nil
+	}
+	return cell
+}
+
+// polygonArea returns the signed area of a polygon in the XY plane,
+// positive if its corners are in counter-clockwise order
+func polygonArea(polygon []m.Vector) float32 {
+	var area float32
+	for i, p := range polygon {
+		q := polygon[(i+1)%len(polygon)]
+		area += p.X*q.Y - q.X*p.Y
+	}
+	return area / 2.0
+}
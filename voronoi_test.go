@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	m "github.com/deosjr/GRayT/src/model"
+)
+
+func TestVoronoiCells(t *testing.T) {
+	min, max := m.Vector{0, 0, 0}, m.Vector{4, 2, 0}
+	for i, tt := range []struct {
+		sites []m.Vector
+		want  [][]m.Vector
+	}{
+		{
+			// two sites split the box in half, a site far outside
+			// the box only completes the triangulation
+			sites: []m.Vector{{2, 1.5, 0}, {2, 0.5, 0}, {100, 1, 0}},
+			want: [][]m.Vector{
+				{{0, 1, 0}, {4, 1, 0}, {4, 2, 0}, {0, 2, 0}},
+				{{0, 0, 0}, {4, 0, 0}, {4, 1, 0}, {0, 1, 0}},
+				nil,
+			},
+		},
+		{
+			// cells clipped by the box on three sides
+			sites: []m.Vector{{1, 1, 0}, {3, 0.5, 0}, {3, 1.5, 0}},
+			want: [][]m.Vector{
+				{{0, 0, 0}, {1.8125, 0, 0}, {2.0625, 1, 0}, {1.8125, 2, 0}, {0, 2, 0}},
+				{{1.8125, 0, 0}, {4, 0, 0}, {4, 1, 0}, {2.0625, 1, 0}},
+				{{2.0625, 1, 0}, {4, 1, 0}, {4, 2, 0}, {1.8125, 2, 0}},
+			},
+		},
+		{
+			// a site on its own has no triangulation
+			sites: []m.Vector{{1, 1, 0}},
+			want:  [][]m.Vector{nil},
+		},
+		{
+			// a repeated site gets no cell of its own
+			sites: []m.Vector{{2, 0.5, 0}, {2, 1.5, 0}, {100, 1, 0}, {2, 0.5, 0}},
+			want: [][]m.Vector{
+				{{0, 0, 0}, {4, 0, 0}, {4, 1, 0}, {0, 1, 0}},
+				{{0, 1, 0}, {4, 1, 0}, {4, 2, 0}, {0, 2, 0}},
+				nil,
+				nil,
+			},
+		},
+		{
+			// nor do sites on a line
+			sites: []m.Vector{{3, 1, 0}, {1, 1, 0}, {2, 1, 0}, {5, 1, 0}},
+			want:  [][]m.Vector{nil, nil, nil, nil},
+		},
+	} {
+		got := voronoiCells(tt.sites, min, max)
+		if len(got) != len(tt.want) {
+			t.Errorf("%d): got %d cells want %d", i, len(got), len(tt.want))
+			continue
+		}
+		for j, cell := range got {
+			if !samePolygon(cell, tt.want[j]) {
+				t.Errorf("%d): cell %d: got %v want %v", i, j, cell, tt.want[j])
+			}
+		}
+	}
+}
+
+func TestVoronoiCellsRandom(t *testing.T) {
+	min, max := m.Vector{-5, -5, 0}, m.Vector{5, 5, 0}
+	r := rand.New(rand.NewSource(1))
+	sites := make([]m.Vector, 20000)
+	for i := range sites {
+		sites[i] = m.Vector{10*r.Float32() - 5, 10*r.Float32() - 5, 0}
+	}
+	cells := voronoiCells(sites, min, max)
+	var total float32
+	for i, cell := range cells {
+		area := polygonArea(cell)
+		if area <= 0 {
+			t.Errorf("cell %d is not counter-clockwise: %v", i, cell)
+		}
+		total += area
+		// each cell is convex and holds its own site
+		for j, p := range cell {
+			q := cell[(j+1)%len(cell)]
+			if cross := (q.X-p.X)*(sites[i].Y-p.Y) - (q.Y-p.Y)*(sites[i].X-p.X); cross < -1e-4 {
+				t.Errorf("cell %d does not hold its site %v", i, sites[i])
+				break
+			}
+		}
+	}
+	if !approx(total, 100) {
+		t.Errorf("cells cover an area of %f, want 100", total)
+	}
+}
+
+// samePolygon compares polygons up to the corner they start from
+func samePolygon(a, b []m.Vector) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	if len(a) == 0 {
+		return true
+	}
+	for offset := range a {
+		same := true
+		for i := range a {
+			if !compareVector(a[(i+offset)%len(a)], b[i]) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return true
+		}
+	}
+	return false
+}
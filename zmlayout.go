@@ -0,0 +1,443 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// maxSolverSteps is the number of changes the solver tries
+// on a layout to get rid of walls that end at a seam
+const maxSolverSteps = 20000
+
+// zmCandidate is a tile from the set in one of its rotations,
+// with its squares as they lie on the board
+type zmCandidate struct {
+	placement zmPlacement
+	squares   [][]rune
+}
+
+// zmSolver fills the board one tile at a time, much like wave function collapse:
+// it always picks the empty spot with the fewest tiles that still fit there,
+// and backtracks whenever a spot has none left or the floor gets split into
+// parts that can no longer be joined by the tiles still to come.
+// Not every pool can be laid out without walls ending at a seam, so tiles
+// that leave a dead end still fit, but those with the fewest are tried first.
+// The solver then keeps swapping and turning tiles as long as that does not
+// add dead ends, to get rid of those that are left.
+type zmSolver struct {
+	n          int
+	width      int
+	length     int
+	candidates []zmCandidate
+	// candidates of each tile in the set
+	rotations [][]int
+	// deadEndsX[a][b] is the number of walls ending at the seam if
+	// candidate b lies next to a along the x-axis, deadEndsZ along the z-axis
+	deadEndsX, deadEndsZ [][]int
+	// copies left of each tile in the pool
+	remaining []int
+	// candidate on each spot of the board, row by row, or -1 if empty
+	spots []int
+	// squares of the whole board by world position [z][x],
+	// zero where no tile has been placed yet
+	grid [][]rune
+	r    *rand.Rand
+}
+
+var errNoLayout = errors.New("no layout with a connected floor")
+
+// solveLayout returns a layout drawn from the tile pool in which every floor
+// square can be reached from every other, and walls reaching the edge of a
+// tile continue on the next tile wherever the pool allows it.
+// The same seed gives the same layout.
+func solveLayout(p ZoneMortalisParameters) (zmLayout, error) {
+	numTiles := p.boardWidth * p.boardLength
+	if pool := len(p.pool()); pool < numTiles {
+		return zmLayout{}, fmt.Errorf("tile pool has %d tiles, need %d", pool, numTiles)
+	}
+	s := newSolver(p)
+	if !s.solve(0) {
+		return zmLayout{}, errNoLayout
+	}
+	s.improve()
+	layout := zmLayout{width: s.width, length: s.length, placements: make([]zmPlacement, numTiles)}
+	for i, c := range s.spots {
+		layout.placements[i] = s.candidates[c].placement
+	}
+	return layout, nil
+}
+
+// newSolver returns a solver with an empty board and the whole pool left
+func newSolver(p ZoneMortalisParameters) *zmSolver {
+	numTiles := p.boardWidth * p.boardLength
+	n := p.tileSet.squaresPerTile
+	s := &zmSolver{
+		n:         n,
+		width:     p.boardWidth,
+		length:    p.boardLength,
+		rotations: make([][]int, len(p.tileSet.tiles)),
+		remaining: make([]int, len(p.tileSet.tiles)),
+		spots:     make([]int, numTiles),
+		grid:      make([][]rune, p.boardLength*n),
+		r:         rand.New(rand.NewSource(p.seed)),
+	}
+	for _, i := range p.pool() {
+		s.remaining[i]++
+	}
+	for i, tile := range p.tileSet.tiles {
+		for _, c := range rotations(i, tile.squares) {
+			s.rotations[i] = append(s.rotations[i], len(s.candidates))
+			s.candidates = append(s.candidates, c)
+		}
+	}
+	s.deadEndsX = make([][]int, len(s.candidates))
+	s.deadEndsZ = make([][]int, len(s.candidates))
+	for a, ca := range s.candidates {
+		s.deadEndsX[a] = make([]int, len(s.candidates))
+		s.deadEndsZ[a] = make([]int, len(s.candidates))
+		for b, cb := range s.candidates {
+			for k := 0; k < n; k++ {
+				// the first row of squares is at the far edge of the tile
+				if !wallsMeet(ca.squares[k][n-1], cb.squares[k][0], alongX) {
+					s.deadEndsX[a][b]++
+				}
+				if !wallsMeet(ca.squares[0][k], cb.squares[n-1][k], alongZ) {
+					s.deadEndsZ[a][b]++
+				}
+			}
+		}
+	}
+	for i := range s.spots {
+		s.spots[i] = -1
+	}
+	for z := range s.grid {
+		s.grid[z] = make([]rune, p.boardWidth*n)
+	}
+	return s
+}
+
+func (s *zmSolver) solve(numPlaced int) bool {
+	if numPlaced == len(s.spots) {
+		return true
+	}
+	spot, options := -1, []zmOption{}
+	for i, c := range s.spots {
+		if c != -1 {
+			continue
+		}
+		o := s.options(i)
+		if len(o) == 0 {
+			return false
+		}
+		if spot == -1 || len(o) < len(options) {
+			spot, options = i, o
+		}
+	}
+	// try the options with the fewest dead ends first, in random order otherwise
+	s.r.Shuffle(len(options), func(i, j int) { options[i], options[j] = options[j], options[i] })
+	sort.SliceStable(options, func(i, j int) bool { return options[i].deadEnds < options[j].deadEnds })
+	x, z := spot%s.width, spot/s.width
+	for _, o := range options {
+		tile := s.candidates[o.candidate].placement.tile
+		s.place(x, z, s.candidates[o.candidate].squares)
+		if s.floorConnected() {
+			s.spots[spot] = o.candidate
+			s.remaining[tile]--
+			if s.solve(numPlaced + 1) {
+				return true
+			}
+			s.remaining[tile]++
+			s.spots[spot] = -1
+		}
+		s.place(x, z, nil)
+	}
+	return false
+}
+
+// improve tries to swap two tiles, turn a tile, or exchange a tile for one
+// left in the pool, and keeps the change if it adds no dead ends and
+// leaves the floor connected. Only the seams around the changed spots
+// are counted again, and only changes that pass are put on the board
+func (s *zmSolver) improve() {
+	cost := s.deadEnds()
+	for step := 0; step < maxSolverSteps && cost > 0; step++ {
+		i, j := s.r.Intn(len(s.spots)), s.r.Intn(len(s.spots))
+		prev := [2]int{s.spots[i], s.spots[j]}
+		before := s.deadEndsAround(i, j)
+		turn := func(spot int) {
+			rotations := s.rotations[s.candidates[s.spots[spot]].placement.tile]
+			s.spots[spot] = rotations[s.r.Intn(len(rotations))]
+		}
+		switch s.r.Intn(3) {
+		case 0:
+			s.spots[i], s.spots[j] = s.spots[j], s.spots[i]
+			turn(i)
+			turn(j)
+		case 1:
+			turn(i)
+		case 2:
+			tile := s.r.Intn(len(s.remaining))
+			if s.remaining[tile] == 0 {
+				continue
+			}
+			s.spots[i] = s.rotations[tile][s.r.Intn(len(s.rotations[tile]))]
+		}
+		after := s.deadEndsAround(i, j)
+		if after > before {
+			s.spots[j], s.spots[i] = prev[1], prev[0]
+			continue
+		}
+		s.update(prev, i, j)
+		if s.floorConnected() {
+			cost += after - before
+			continue
+		}
+		next := [2]int{s.spots[i], s.spots[j]}
+		s.spots[j], s.spots[i] = prev[1], prev[0]
+		s.update(next, i, j)
+	}
+}
+
+// update puts the tiles now on spots i and j on the board and takes
+// them from the pool, returning those in prev they replace
+func (s *zmSolver) update(prev [2]int, i, j int) {
+	for k, spot := range []int{i, j} {
+		if k == 1 && i == j {
+			break
+		}
+		if prev[k] == s.spots[spot] {
+			continue
+		}
+		s.remaining[s.candidates[prev[k]].placement.tile]++
+		s.remaining[s.candidates[s.spots[spot]].placement.tile]--
+		s.place(spot%s.width, spot/s.width, s.candidates[s.spots[spot]].squares)
+	}
+}
+
+// deadEnds counts the walls ending at a seam on the whole board
+func (s *zmSolver) deadEnds() int {
+	deadEnds := 0
+	for i := range s.spots {
+		deadEnds += s.deadEndsBefore(i, -1)
+	}
+	return deadEnds
+}
+
+// deadEndsAround counts the walls ending at the seams of spots i and j,
+// counting the seam between them once if they are neighbours
+func (s *zmSolver) deadEndsAround(i, j int) int {
+	deadEnds := s.deadEndsBefore(i, -1) + s.deadEndsAfter(i, -1)
+	if j != i {
+		deadEnds += s.deadEndsBefore(j, i) + s.deadEndsAfter(j, i)
+	}
+	return deadEnds
+}
+
+// deadEndsBefore counts the walls ending at the seams of spot i with its
+// neighbours left of it and nearer to the front, leaving out spot skip
+func (s *zmSolver) deadEndsBefore(i, skip int) int {
+	deadEnds := 0
+	if i%s.width > 0 && i-1 != skip {
+		deadEnds += s.deadEndsX[s.spots[i-1]][s.spots[i]]
+	}
+	if i >= s.width && i-s.width != skip {
+		deadEnds += s.deadEndsZ[s.spots[i-s.width]][s.spots[i]]
+	}
+	return deadEnds
+}
+
+// deadEndsAfter counts the walls ending at the seams of spot i with its
+// neighbours right of it and further back, leaving out spot skip
+func (s *zmSolver) deadEndsAfter(i, skip int) int {
+	deadEnds := 0
+	if i%s.width < s.width-1 && i+1 != skip {
+		deadEnds += s.deadEndsX[s.spots[i]][s.spots[i+1]]
+	}
+	if i+s.width < len(s.spots) && i+s.width != skip {
+		deadEnds += s.deadEndsZ[s.spots[i]][s.spots[i+s.width]]
+	}
+	return deadEnds
+}
+
+// zmOption is a candidate for a spot on the board and
+// the number of walls it leaves ending at its seams
+type zmOption struct {
+	candidate int
+	deadEnds  int
+}
+
+// options returns the candidates left in the pool for a spot,
+// with the dead ends they leave next to the tiles already placed around it
+func (s *zmSolver) options(spot int) []zmOption {
+	x, z := spot%s.width, spot/s.width
+	neighbour := func(x, z int) int {
+		if x < 0 || x >= s.width || z < 0 || z >= s.length {
+			return -1
+		}
+		return s.spots[z*s.width+x]
+	}
+	left, right := neighbour(x-1, z), neighbour(x+1, z)
+	near, far := neighbour(x, z-1), neighbour(x, z+1)
+	options := []zmOption{}
+	for c, candidate := range s.candidates {
+		if s.remaining[candidate.placement.tile] == 0 {
+			continue
+		}
+		deadEnds := 0
+		if left != -1 {
+			deadEnds += s.deadEndsX[left][c]
+		}
+		if right != -1 {
+			deadEnds += s.deadEndsX[c][right]
+		}
+		if near != -1 {
+			deadEnds += s.deadEndsZ[near][c]
+		}
+		if far != -1 {
+			deadEnds += s.deadEndsZ[c][far]
+		}
+		options = append(options, zmOption{c, deadEnds})
+	}
+	return options
+}
+
+// place puts squares on the tile at x, z or clears it if squares is nil
+func (s *zmSolver) place(x, z int, squares [][]rune) {
+	for sz := 0; sz < s.n; sz++ {
+		for sx := 0; sx < s.n; sx++ {
+			var r rune
+			if squares != nil {
+				// the first row is at the far edge of the tile
+				r = squares[s.n-1-sz][sx]
+			}
+			s.grid[z*s.n+sz][x*s.n+sx] = r
+		}
+	}
+}
+
+// wallsMeet reports whether two neighbouring squares agree on a wall running
+// between them, walls and doors running along them as told by along;
+// corners can end a wall from any side
+func wallsMeet(a, b rune, along func(rune) bool) bool {
+	reaches := along
+	takes := func(r rune) bool { return along(r) || r == 'x' }
+	if reaches(a) && !takes(b) {
+		return false
+	}
+	if reaches(b) && !takes(a) {
+		return false
+	}
+	return true
+}
+
+// canStep tells whether models can step between two neighbouring walkable
+// squares: up onto a platform only from stairs, a hatch or more platform.
+// A square where no tile has been placed yet could still be any of those.
+func canStep(a, b rune) bool {
+	onto := func(r rune) bool { return r == 0 || raised(r) || strings.ContainsRune("^<v>", r) }
+	if a == '#' && !onto(b) {
+		return false
+	}
+	if b == '#' && !onto(a) {
+		return false
+	}
+	return true
+}
+
+// floorConnected checks that all walkable squares placed so far can reach
+// each other, walking over squares where no tile has been placed yet as if
+// they were floor, stairs or platform, see canStep. Tiles placed later can
+// only add walls, so if this fails the floor will never be connected.
+func (s *zmSolver) floorConnected() bool {
+	width, length := len(s.grid[0]), len(s.grid)
+	start, numFloor := -1, 0
+	for z, row := range s.grid {
+		for x, r := range row {
+			if !walkable(r) {
+				continue
+			}
+			numFloor++
+			if start == -1 {
+				start = z*width + x
+			}
+		}
+	}
+	if start == -1 {
+		return true
+	}
+	seen := make([]bool, width*length)
+	seen[start] = true
+	queue := []int{start}
+	reached := 0
+	for len(queue) > 0 {
+		q := queue[0]
+		queue = queue[1:]
+		x, z := q%width, q/width
+		if walkable(s.grid[z][x]) {
+			reached++
+		}
+		for _, next := range [][2]int{{x - 1, z}, {x + 1, z}, {x, z - 1}, {x, z + 1}} {
+			nx, nz := next[0], next[1]
+			if nx < 0 || nx >= width || nz < 0 || nz >= length || seen[nz*width+nx] {
+				continue
+			}
+			if r := s.grid[nz][nx]; !walkable(r) && r != 0 || !canStep(s.grid[z][x], r) {
+				continue
+			}
+			seen[nz*width+nx] = true
+			queue = append(queue, nz*width+nx)
+		}
+	}
+	return reached == numFloor
+}
+
+// rotations returns the distinct rotations of a tile
+func rotations(tile int, squares [][]rune) []zmCandidate {
+	candidates := []zmCandidate{}
+	rotated := squares
+	for n := 0; n < 4; n++ {
+		duplicate := false
+		for _, c := range candidates {
+			if sameSquares(c.squares, rotated) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			candidates = append(candidates, zmCandidate{placement: zmPlacement{tile: tile, rotation: n}, squares: rotated})
+		}
+		rotated = rotateSquares(rotated)
+	}
+	return candidates
+}
+
+// rotateSquares turns a tile a quarter turn the same way buildBoard does:
+// the far edge becomes the left edge. Walls change direction along with it.
+func rotateSquares(squares [][]rune) [][]rune {
+	n := len(squares)
+	rotated := make([][]rune, n)
+	for z := range rotated {
+		rotated[z] = make([]rune, n)
+	}
+	for z, row := range squares {
+		for x, r := range row {
+			if turned, ok := zmTurned[r]; ok {
+				r = turned
+			}
+			rotated[n-1-x][z] = r
+		}
+	}
+	return rotated
+}
+
+func sameSquares(a, b [][]rune) bool {
+	for z := range a {
+		if string(a[z]) != string(b[z]) {
+			return false
+		}
+	}
+	return true
+}
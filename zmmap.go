@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// a layout file lists the size of the board and the tile on each spot,
+// by name and number of quarter turns, counting spots from the near left corner:
+//
+//	board 4 4
+//	tile 0 0 alpha 1
+//
+// ExportZoneMortalis writes one next to an ASCII map and an SVG plan of the board,
+// and NewZoneMortalis builds exactly that board again from it as layoutFile.
+
+// ExportZoneMortalis lays out a board as NewZoneMortalis would and writes
+// its layout to basename.txt, an ASCII map to basename.map and a top-down
+// plan to basename.svg, so a board can be checked before rendering it
+func ExportZoneMortalis(p ZoneMortalisParameters, basename string) error {
+	p = p.withDefaults()
+	layout, err := p.layout()
+	if err != nil {
+		return err
+	}
+	for _, export := range []struct {
+		ext   string
+		write func(io.Writer) error
+	}{
+		{".txt", func(w io.Writer) error { return writeLayout(w, layout, p.tileSet) }},
+		{".map", func(w io.Writer) error { return writeASCIIMap(w, layout, p.tileSet) }},
+		{".svg", func(w io.Writer) error { return writeSVGMap(w, layout, p.tileSet, 10) }},
+	} {
+		if err := writeFile(basename+export.ext, export.write); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(filename string, write func(io.Writer) error) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadLayout reads a layout file for tiles from tileSet
+func LoadLayout(filename string, tileSet zmTileSet) (zmLayout, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return zmLayout{}, err
+	}
+	defer file.Close()
+	return readLayout(file, tileSet)
+}
+
+func writeLayout(w io.Writer, layout zmLayout, tileSet zmTileSet) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Zone Mortalis layout: tile x z name quarter-turns")
+	fmt.Fprintf(bw, "board %d %d\n", layout.width, layout.length)
+	for i, pl := range layout.placements {
+		fmt.Fprintf(bw, "tile %d %d %s %d\n", i%layout.width, i/layout.width, tileSet.tiles[pl.tile].name, pl.rotation)
+	}
+	return bw.Flush()
+}
+
+func readLayout(r io.Reader, tileSet zmTileSet) (zmLayout, error) {
+	tiles := map[string]int{}
+	for i, tile := range tileSet.tiles {
+		tiles[tile.name] = i
+	}
+	layout := zmLayout{}
+	placed := []bool{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		invalid := fmt.Errorf("invalid line in layout: %s", scanner.Text())
+		switch fields[0] {
+		case "board":
+			if len(fields) != 3 {
+				return zmLayout{}, invalid
+			}
+			width, err1 := strconv.Atoi(fields[1])
+			length, err2 := strconv.Atoi(fields[2])
+			if err1 != nil || err2 != nil || width <= 0 || length <= 0 {
+				return zmLayout{}, invalid
+			}
+			layout = zmLayout{width: width, length: length, placements: make([]zmPlacement, width*length)}
+			placed = make([]bool, width*length)
+		case "tile":
+			if len(fields) != 5 {
+				return zmLayout{}, invalid
+			}
+			if layout.width == 0 {
+				return zmLayout{}, fmt.Errorf("layout lists tiles before the board")
+			}
+			x, err1 := strconv.Atoi(fields[1])
+			z, err2 := strconv.Atoi(fields[2])
+			rotation, err3 := strconv.Atoi(fields[4])
+			if err1 != nil || err2 != nil || err3 != nil || x < 0 || x >= layout.width || z < 0 || z >= layout.length || rotation < 0 || rotation > 3 {
+				return zmLayout{}, invalid
+			}
+			tile, ok := tiles[fields[3]]
+			if !ok {
+				return zmLayout{}, fmt.Errorf("unknown tile in layout: %s", fields[3])
+			}
+			if placed[z*layout.width+x] {
+				return zmLayout{}, fmt.Errorf("tile placed twice at %d %d", x, z)
+			}
+			layout.placements[z*layout.width+x] = zmPlacement{tile: tile, rotation: rotation}
+			placed[z*layout.width+x] = true
+		default:
+			return zmLayout{}, invalid
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return zmLayout{}, err
+	}
+	if layout.width == 0 {
+		return zmLayout{}, fmt.Errorf("layout has no board")
+	}
+	for i, ok := range placed {
+		if !ok {
+			return zmLayout{}, fmt.Errorf("no tile at %d %d", i%layout.width, i/layout.width)
+		}
+	}
+	return layout, nil
+}
+
+// boardSquares returns the squares of the whole board as they would be
+// in a tile file: the first row at the far edge, with '.' for floor
+func boardSquares(layout zmLayout, tileSet zmTileSet) [][]rune {
+	n := tileSet.squaresPerTile
+	squares := make([][]rune, layout.length*n)
+	for z := range squares {
+		squares[z] = make([]rune, layout.width*n)
+	}
+	for i, pl := range layout.placements {
+		tile := tileSet.tiles[pl.tile].squares
+		for k := 0; k < pl.rotation; k++ {
+			tile = rotateSquares(tile)
+		}
+		// the first row of tiles on the board is at the near edge
+		top, left := (layout.length-1-i/layout.width)*n, i%layout.width*n
+		for z, row := range tile {
+			for x, r := range row {
+				if r == ' ' {
+					r = '.'
+				}
+				squares[top+z][left+x] = r
+			}
+		}
+	}
+	return squares
+}
+
+func writeASCIIMap(w io.Writer, layout zmLayout, tileSet zmTileSet) error {
+	bw := bufio.NewWriter(w)
+	for _, row := range boardSquares(layout, tileSet) {
+		fmt.Fprintln(bw, string(row))
+	}
+	return bw.Flush()
+}
+
+// writeSVGMap draws the board from above with the far edge at the top,
+// each square size pixels wide, and the name and rotation of each tile
+func writeSVGMap(w io.Writer, layout zmLayout, tileSet zmTileSet, size int) error {
+	n := tileSet.squaresPerTile
+	squares := boardSquares(layout, tileSet)
+	bw := bufio.NewWriter(w)
+	width, height := layout.width*n*size, layout.length*n*size
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	rect := func(x, y, w, h int, style string) {
+		fmt.Fprintf(bw, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" %s/>\n", x, y, w, h, style)
+	}
+	rect(0, 0, width, height, `fill="#bbb"`)
+	wall := size / 3
+	for z, row := range squares {
+		for x, r := range row {
+			px, py := x*size, z*size
+			switch r {
+			case '.':
+				rect(px, py, size, size, `fill="none" stroke="#999" stroke-width="1"`)
+			case 'x':
+				rect(px, py, size, size, `fill="#222"`)
+			case '-':
+				rect(px, py+wall, size, size-2*wall, `fill="#444"`)
+			case '|':
+				rect(px+wall, py, size-2*wall, size, `fill="#444"`)
+			case '=':
+				rect(px, py+wall, size, size-2*wall, `fill="#a52"`)
+			case '!':
+				rect(px+wall, py, size-2*wall, size, `fill="#a52"`)
+			case '_':
+				rect(px, py+wall, size, size-2*wall, `fill="none" stroke="#a52" stroke-width="1"`)
+			case ':':
+				rect(px+wall, py, size-2*wall, size, `fill="none" stroke="#a52" stroke-width="1"`)
+			case '#':
+				rect(px, py, size, size, `fill="#777" stroke="#555" stroke-width="1"`)
+			case 'o':
+				rect(px, py, size, size, `fill="#777" stroke="#555" stroke-width="1"`)
+				fmt.Fprintf(bw, "<circle cx=\"%g\" cy=\"%g\" r=\"%g\" fill=\"#333\"/>\n", float32(px)+float32(size)/2.0, float32(py)+float32(size)/2.0, float32(size)/4.0)
+			case '^', '<', 'v', '>':
+				rect(px, py, size, size, `fill="#999" stroke="#555" stroke-width="1"`)
+				// an arrow pointing up the stairs
+				c, d := float32(size)/2.0, float32(size)/3.0
+				tip, left, right := [2]float32{0, -d}, [2]float32{-d, d}, [2]float32{d, d}
+				for i := 0; i < strings.IndexRune("^>v<", r); i++ {
+					for _, v := range []*[2]float32{&tip, &left, &right} {
+						v[0], v[1] = -v[1], v[0]
+					}
+				}
+				fmt.Fprintf(bw, "<polygon points=\"%g,%g %g,%g %g,%g\" fill=\"#555\"/>\n",
+					float32(px)+c+tip[0], float32(py)+c+tip[1], float32(px)+c+left[0], float32(py)+c+left[1], float32(px)+c+right[0], float32(py)+c+right[1])
+			}
+		}
+	}
+	for i, pl := range layout.placements {
+		px, py := i%layout.width*n*size, (layout.length-1-i/layout.width)*n*size
+		rect(px, py, n*size, n*size, `fill="none" stroke="#c00" stroke-width="1" stroke-dasharray="4 2"`)
+		fmt.Fprintf(bw, "<text x=\"%d\" y=\"%d\" font-size=\"%d\" fill=\"#c00\">%s %d</text>\n", px+2, py+size, size, html.EscapeString(tileSet.tiles[pl.tile].name), pl.rotation)
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
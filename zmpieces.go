@@ -0,0 +1,203 @@
+package main
+
+import (
+	"math"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GenGeo/gen"
+)
+
+// default pieces for a Zone Mortalis board, sized to a square of the grid.
+// Walls stand 1.5 squares high (~75mm) and are a third of a square thick,
+// running through the middle of their square; corner pillars are a bit wider.
+// Platforms are level with the top of the walls.
+const zmWallHeight = 1.5
+
+// zmWall returns a bulkhead section running along the x-axis: a plate with
+// raised panels on both sides, a pipe held by a clamp on one side and a
+// thinner conduit low down on the other
+func zmWall(mat m.Material, square float32) m.Object {
+	h := zmWallHeight * square
+	t := square / 3.0
+	z0, z1 := t, 2*t
+	panel := square / 20.0
+	triangles := cuboid(mat, m.Vector{0, 0, z0}, m.Vector{square, h, z1})
+	triangles = append(triangles, cuboid(mat, m.Vector{0.1 * square, 0.1 * h, z0 - panel}, m.Vector{0.9 * square, 0.65 * h, z0})...)
+	triangles = append(triangles, cuboid(mat, m.Vector{0.1 * square, 0.1 * h, z1}, m.Vector{0.9 * square, 0.65 * h, z1 + panel})...)
+
+	r := square / 12.0
+	pipeY, pipeZ := 0.82*h, z0-r
+	triangles = append(triangles, cuboid(mat, m.Vector{0.45 * square, pipeY - 1.3*r, z0 - 2.3*r}, m.Vector{0.55 * square, pipeY + 1.3*r, z0})...)
+	return m.NewComplexObject([]m.Object{
+		m.NewTriangleComplexObject(triangles),
+		pipe(mat, m.Vector{0, pipeY, pipeZ}, r, square),
+		pipe(mat, m.Vector{0, 0.2 * h, z1 + r/2.0}, r/2.0, square),
+	})
+}
+
+// arms of a corner piece, by the edge of its square they reach.
+// A quarter turn takes each arm to the next, see turnArms
+const (
+	zmRight = 1 << iota // +x
+	zmFar               // +z
+	zmLeft              // -x
+	zmNear              // -z
+)
+
+// turnArms returns the arms of a corner piece after a number of quarter turns
+func turnArms(arms, quarterTurns int) int {
+	k := quarterTurns % 4
+	return (arms<<k | arms>>(4-k)) & 0xf
+}
+
+// zmCorner returns a square pillar on a plinth with a cap, centered on the square,
+// with plain wall sections from the pillar to each edge of the square in arms
+func zmCorner(mat m.Material, square float32, arms int) m.Object {
+	h := zmWallHeight * square
+	c := square / 2.0
+	block := func(halfWidth, y0, y1 float32) []m.Triangle {
+		return cuboid(mat, m.Vector{c - halfWidth, y0, c - halfWidth}, m.Vector{c + halfWidth, y1, c + halfWidth})
+	}
+	triangles := block(0.35*square, 0, 0.1*h)
+	triangles = append(triangles, block(0.25*square, 0.1*h, h)...)
+	triangles = append(triangles, block(0.3*square, h, 1.1*h)...)
+	t := square / 3.0
+	p := 0.25 * square
+	for _, arm := range []struct {
+		arm        int
+		pmin, pmax m.Vector
+	}{
+		{zmRight, m.Vector{c + p, 0, t}, m.Vector{square, h, 2 * t}},
+		{zmFar, m.Vector{t, 0, c + p}, m.Vector{2 * t, h, square}},
+		{zmLeft, m.Vector{0, 0, t}, m.Vector{c - p, h, 2 * t}},
+		{zmNear, m.Vector{t, 0, 0}, m.Vector{2 * t, h, c - p}},
+	} {
+		if arms&arm.arm != 0 {
+			triangles = append(triangles, cuboid(mat, arm.pmin, arm.pmax)...)
+		}
+	}
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// zmFloor returns a grated floor plate. It is slightly smaller than a square
+// and centered on it, to leave a seam between floor plates.
+func zmFloor(mat m.Material, square float32) m.Object {
+	inset := square / 100.0
+	return m.NewTriangleComplexObject(grating(mat, m.Vector{inset, 0, inset}, 0.98*square, square))
+}
+
+// grating returns a plate of size x size with its corner at p: a frame with
+// bars running along the z-axis, all in proportion to a square
+func grating(mat m.Material, p m.Vector, s, square float32) []m.Triangle {
+	t := square / 20.0
+	frame := square / 10.0
+	box := func(x0, z0, x1, z1, h float32) []m.Triangle {
+		return cuboid(mat, p.Add(m.Vector{x0, 0, z0}), p.Add(m.Vector{x1, h, z1}))
+	}
+	triangles := box(0, 0, s, frame, t)
+	triangles = append(triangles, box(0, s-frame, s, s, t)...)
+	triangles = append(triangles, box(0, frame, frame, s-frame, t)...)
+	triangles = append(triangles, box(s-frame, frame, s, s-frame, t)...)
+	numBars := 5
+	bar := square / 25.0
+	gap := (s - 2*frame - float32(numBars)*bar) / float32(numBars+1)
+	for i := 0; i < numBars; i++ {
+		x := frame + gap + float32(i)*(gap+bar)
+		triangles = append(triangles, box(x, frame, x+bar, s-frame, 0.8*t)...)
+	}
+	return triangles
+}
+
+// zmDoor returns a bulkhead door in a wall along the x-axis: a frame and
+// a lintel, closed by a door panel with a bar across both sides. Open doors
+// have their panel slid up out of sight into the lintel.
+func zmDoor(mat m.Material, square float32, open bool) m.Object {
+	h := zmWallHeight * square
+	t := square / 3.0
+	z0, z1 := t, 2*t
+	post := 0.1 * square
+	top := 1.1 * square
+	triangles := cuboid(mat, m.Vector{0, 0, z0 - post/2}, m.Vector{post, h, z1 + post/2})
+	triangles = append(triangles, cuboid(mat, m.Vector{square - post, 0, z0 - post/2}, m.Vector{square, h, z1 + post/2})...)
+	triangles = append(triangles, cuboid(mat, m.Vector{post, top, z0}, m.Vector{square - post, h, z1})...)
+	if open {
+		return m.NewTriangleComplexObject(triangles)
+	}
+	panel := square / 10.0
+	mid := square / 2.0
+	triangles = append(triangles, cuboid(mat, m.Vector{post, 0, mid - panel}, m.Vector{square - post, top, mid + panel})...)
+	triangles = append(triangles, cuboid(mat, m.Vector{post, 0.45 * top, mid - 2*panel}, m.Vector{square - post, 0.55 * top, mid + 2*panel})...)
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// zmPlatform returns a grated deck at the height of the walls on four legs
+func zmPlatform(mat m.Material, square float32) m.Object {
+	h := zmWallHeight * square
+	t := square / 20.0
+	leg := 0.08 * square
+	triangles := grating(mat, m.Vector{0, h - t, 0}, square, square)
+	for _, x := range []float32{leg, square - 2*leg} {
+		for _, z := range []float32{leg, square - 2*leg} {
+			triangles = append(triangles, cuboid(mat, m.Vector{x, 0, z}, m.Vector{x + leg, h - t, z + leg})...)
+		}
+	}
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// zmHatch returns a platform deck around a hatch, its lid swung open
+// to stand at the far side, with a ladder from the floor up through it
+func zmHatch(mat m.Material, square float32) m.Object {
+	h := zmWallHeight * square
+	t := square / 20.0
+	leg := 0.08 * square
+	lo, hi := 0.3*square, 0.7*square
+	deck := func(x0, z0, x1, z1 float32) []m.Triangle {
+		return cuboid(mat, m.Vector{x0, h - t, z0}, m.Vector{x1, h, z1})
+	}
+	triangles := deck(0, 0, square, lo)
+	triangles = append(triangles, deck(0, hi, square, square)...)
+	triangles = append(triangles, deck(0, lo, lo, hi)...)
+	triangles = append(triangles, deck(hi, lo, square, hi)...)
+	for _, x := range []float32{leg, square - 2*leg} {
+		for _, z := range []float32{leg, square - 2*leg} {
+			triangles = append(triangles, cuboid(mat, m.Vector{x, 0, z}, m.Vector{x + leg, h - t, z + leg})...)
+		}
+	}
+	// the lid stands on the deck behind the hatch
+	triangles = append(triangles, cuboid(mat, m.Vector{lo, h, hi}, m.Vector{hi, h + 0.4*square, hi + t})...)
+	// the ladder leans against the far edge of the hatch
+	rail := square / 25.0
+	z0, z1 := hi-2*rail, hi
+	triangles = append(triangles, cuboid(mat, m.Vector{lo + rail, 0, z0}, m.Vector{lo + 2*rail, h, z1})...)
+	triangles = append(triangles, cuboid(mat, m.Vector{hi - 2*rail, 0, z0}, m.Vector{hi - rail, h, z1})...)
+	numRungs := 5
+	for i := 1; i <= numRungs; i++ {
+		y := float32(i) * (h - t) / float32(numRungs+1)
+		triangles = append(triangles, cuboid(mat, m.Vector{lo + 2*rail, y, z0}, m.Vector{hi - 2*rail, y + rail, z0 + rail})...)
+	}
+	return m.NewTriangleComplexObject(triangles)
+}
+
+// zmStairs returns a flight of steps rising towards +z, from the floor
+// to the height of a platform on the next square
+func zmStairs(mat m.Material, square float32) m.Object {
+	h := zmWallHeight * square
+	numSteps := 6
+	triangles := []m.Triangle{}
+	for i := 0; i < numSteps; i++ {
+		z := float32(i) * square / float32(numSteps)
+		y := float32(i+1) * h / float32(numSteps)
+		triangles = append(triangles, cuboid(mat, m.Vector{0.1 * square, 0, z}, m.Vector{0.9 * square, y, square})...)
+	}
+	return m.NewTriangleComplexObject(triangles)
+}
+
+func cuboid(mat m.Material, pmin, pmax m.Vector) []m.Triangle {
+	return m.NewCuboid(m.NewAABB(pmin, pmax), mat).Tesselate()
+}
+
+// pipe returns a cylinder of radius r along the x-axis, starting at p
+func pipe(mat m.Material, p m.Vector, r, length float32) m.Object {
+	points := gen.NewCircle(p, r).PointsPhaseRange(0, 2*math.Pi, 13, ey, ez)
+	return gen.ExtrudeSolidFace(points[:12], m.Vector{length, 0, 0}, mat)
+}
@@ -1,179 +1,550 @@
 package main
 
 import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io/fs"
 	"math"
-	"math/rand"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	m "github.com/deosjr/GRayT/src/model"
-	"github.com/deosjr/GenGeo/gen"
 )
 
-// 4x4 tiles
+// a board of 4x4 tiles by default, laid out by solveLayout
 // each tile is 6x6 squares and 1' x 1'
 // each square is ~50mm x 50mm
-// squares can be walls in one of two directions or corners joining wall sections
-// otherwise they are empty squares
-
-var zm_alpha = [6][6]rune{
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', 'x', '-', '-', 'x', '-'},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', 'x', ' ', ' ', ' ', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-}
-
-var zm_beta = [6][6]rune{
-	{' ', ' ', ' ', ' ', '|', ' '},
-	{' ', 'x', '-', '-', 'x', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', 'x', '-', '-', 'x', ' '},
-	{' ', ' ', ' ', ' ', '|', ' '},
-}
-
-var zm_gamma = [6][6]rune{
-	{' ', '|', ' ', ' ', '|', ' '},
-	{' ', 'x', ' ', ' ', 'x', '-'},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', 'x', ' ', ' ', 'x', '-'},
-	{' ', '|', ' ', ' ', '|', ' '},
-}
-
-var zm_delta = [6][6]rune{
-	{' ', '|', ' ', ' ', '|', ' '},
-	{'-', 'x', ' ', ' ', 'x', '-'},
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{'-', 'x', ' ', ' ', 'x', '-'},
-	{' ', '|', ' ', ' ', '|', ' '},
-}
-
-var zm_epsilon = [6][6]rune{
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', 'x', ' ', ' ', ' ', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', 'x', ' ', ' ', 'x', '-'},
-	{' ', ' ', ' ', ' ', ' ', ' '},
-}
-
-var zm_zeta = [6][6]rune{
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', ' ', ' ', ' ', 'x', ' '},
-	{' ', ' ', ' ', ' ', '|', ' '},
-	{' ', ' ', ' ', ' ', '|', ' '},
-	{' ', ' ', ' ', ' ', 'x', ' '},
-	{' ', ' ', ' ', ' ', '|', ' '},
-}
-
-var zm_eta = [6][6]rune{
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', 'x', ' ', ' ', ' ', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-	{' ', 'x', '-', '-', 'x', ' '},
-	{' ', '|', ' ', ' ', ' ', ' '},
-}
-
-var zm_theta = [6][6]rune{
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', ' ', ' ', ' ', ' ', ' '},
-	{' ', ' ', ' ', ' ', ' ', ' '},
+// squares can be walls or doors in one of two directions, corners joining wall sections,
+// raised platforms, hatches in them or stairs leading up to them; otherwise they are empty squares
+
+// tiles are defined in text files, one line per row of squares from the far edge
+// of the tile to the near edge, using the same glyphs:
+//
+//	'x' corner, joining the walls and doors next to it
+//	'-' '|' wall along the x-axis and the z-axis
+//	'=' '!' closed door in a wall along the x-axis and the z-axis
+//	'_' ':' open door in a wall along the x-axis and the z-axis
+//	'#' platform at the height of the walls
+//	'o' hatch in a platform, with a ladder up from the floor
+//	'^' '<' 'v' '>' stairs rising towards the far edge, the left, the near edge and the right
+//	' ' empty square
+//
+// Doors sit in a wall line, between walls, other doors or corners,
+// stairs lead up to a platform or hatch, and hatches are next to a platform
+// or another hatch, unless they are at the edge of the tile.
+// Rows shorter than the tile and missing rows at the end are empty squares.
+// A tile set manifest lists the size of its tiles and the number of copies of each:
+//
+//	squares 6
+//	tile alpha.txt 2
+//
+// The core set of eight tiles, alpha to theta, is embedded in the binary.
+
+//go:embed zonemortalis
+var zmDefaultFS embed.FS
+
+type zmTile struct {
+	name    string
+	squares [][]rune
+}
+
+type zmTileSet struct {
+	squaresPerTile int
+	tiles          []zmTile
+	// number of copies of each tile in the set
+	counts []int
+}
+
+// defaultTileSet returns the embedded core tile set
+func defaultTileSet() zmTileSet {
+	tileSet, err := loadTileSet(zmDefaultFS, "zonemortalis/tileset.txt")
+	if err != nil {
+		panic(err)
+	}
+	return tileSet
+}
+
+// LoadTileSet reads a tile set manifest and the tiles it lists,
+// with paths relative to the manifest
+func LoadTileSet(filename string) (zmTileSet, error) {
+	return loadTileSet(os.DirFS(filepath.Dir(filename)), filepath.Base(filename))
+}
+
+func loadTileSet(fsys fs.FS, manifest string) (zmTileSet, error) {
+	file, err := fsys.Open(manifest)
+	if err != nil {
+		return zmTileSet{}, err
+	}
+	defer file.Close()
+
+	tileSet := zmTileSet{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "squares":
+			if len(fields) != 2 {
+				return zmTileSet{}, fmt.Errorf("invalid line in manifest: %s", scanner.Text())
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return zmTileSet{}, err
+			}
+			tileSet.squaresPerTile = n
+		case "tile":
+			if len(fields) != 3 {
+				return zmTileSet{}, fmt.Errorf("invalid line in manifest: %s", scanner.Text())
+			}
+			if tileSet.squaresPerTile == 0 {
+				return zmTileSet{}, fmt.Errorf("manifest lists tiles before their size")
+			}
+			count, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return zmTileSet{}, err
+			}
+			tile, err := loadTile(fsys, path.Join(path.Dir(manifest), fields[1]), tileSet.squaresPerTile)
+			if err != nil {
+				return zmTileSet{}, err
+			}
+			tileSet.tiles = append(tileSet.tiles, tile)
+			tileSet.counts = append(tileSet.counts, count)
+		default:
+			return zmTileSet{}, fmt.Errorf("invalid line in manifest: %s", scanner.Text())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return zmTileSet{}, err
+	}
+	return tileSet, nil
+}
+
+func loadTile(fsys fs.FS, filename string, n int) (zmTile, error) {
+	file, err := fsys.Open(filename)
+	if err != nil {
+		return zmTile{}, err
+	}
+	defer file.Close()
+
+	squares, err := readTile(bufio.NewScanner(file), n)
+	if err != nil {
+		return zmTile{}, fmt.Errorf("%s: %s", filename, err)
+	}
+	name := strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+	return zmTile{name: name, squares: squares}, nil
+}
+
+// readTile reads n rows of n squares, padding with empty squares
+func readTile(scanner *bufio.Scanner, n int) ([][]rune, error) {
+	squares := make([][]rune, n)
+	for i := range squares {
+		squares[i] = []rune(strings.Repeat(" ", n))
+	}
+	z := 0
+	for scanner.Scan() {
+		row := []rune(strings.TrimRight(scanner.Text(), "\r"))
+		if len(row) == 0 {
+			z++
+			continue
+		}
+		if z >= n {
+			return nil, fmt.Errorf("more than %d rows", n)
+		}
+		if len(row) > n {
+			return nil, fmt.Errorf("row %d is longer than %d squares", z, n)
+		}
+		for x, r := range row {
+			if !strings.ContainsRune(zmGlyphs, r) {
+				return nil, fmt.Errorf("unexpected glyph %q in row %d", r, z)
+			}
+			squares[z][x] = r
+		}
+		z++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return squares, checkSquares(squares)
+}
+
+const zmGlyphs = "x-|=!_:#o^<v> "
+
+// zmTurned is the glyph for a square after a quarter turn of its tile,
+// which takes the far edge of the tile to the left edge
+var zmTurned = map[rune]rune{
+	'-': '|', '|': '-',
+	'=': '!', '!': '=',
+	'_': ':', ':': '_',
+	'^': '<', '<': 'v', 'v': '>', '>': '^',
+}
+
+// alongX and alongZ tell whether a glyph is a wall or door running along that axis
+func alongX(r rune) bool { return r == '-' || r == '=' || r == '_' }
+func alongZ(r rune) bool { return r == '|' || r == '!' || r == ':' }
+
+// walkable tells whether models can move through a square
+func walkable(r rune) bool { return strings.ContainsRune(" =!_:#o^<v>", r) }
+
+// raised tells whether a glyph is part of a platform
+func raised(r rune) bool { return r == '#' || r == 'o' }
+
+// checkSquares enforces the placement rules for doors, stairs and hatches
+func checkSquares(squares [][]rune) error {
+	n := len(squares)
+	at := func(x, z int) (rune, bool) {
+		if x < 0 || x >= n || z < 0 || z >= n {
+			return 0, false
+		}
+		return squares[z][x], true
+	}
+	// the square in front of stairs going up, by rows from the far edge
+	up := map[rune][2]int{'^': {0, -1}, '<': {-1, 0}, 'v': {0, 1}, '>': {1, 0}}
+	for z, row := range squares {
+		for x, r := range row {
+			switch {
+			case r == '=' || r == '_':
+				for _, dx := range []int{-1, 1} {
+					if q, ok := at(x+dx, z); ok && !alongX(q) && q != 'x' {
+						return fmt.Errorf("door at row %d square %d is not in a wall", z, x)
+					}
+				}
+			case r == '!' || r == ':':
+				for _, dz := range []int{-1, 1} {
+					if q, ok := at(x, z+dz); ok && !alongZ(q) && q != 'x' {
+						return fmt.Errorf("door at row %d square %d is not in a wall", z, x)
+					}
+				}
+			case up[r] != [2]int{}:
+				if q, ok := at(x+up[r][0], z+up[r][1]); ok && !raised(q) {
+					return fmt.Errorf("stairs at row %d square %d do not lead to a platform", z, x)
+				}
+			case r == 'o':
+				inPlatform := false
+				for _, d := range [][2]int{{1, 0}, {0, -1}, {-1, 0}, {0, 1}} {
+					if q, ok := at(x+d[0], z+d[1]); !ok || raised(q) {
+						inPlatform = true
+					}
+				}
+				if !inPlatform {
+					return fmt.Errorf("hatch at row %d square %d is not in a platform", z, x)
+				}
+			}
+		}
+	}
+	return nil
 }
 
 type ZoneMortalisParameters struct {
-	floor    m.Object
-	wall     m.Object
-	corner   m.Object
+	// pieces cover one square each, spanning from the origin to
+	// (square, square) in the XZ plane, see squareFrame. Walls and doors
+	// run along the x-axis and stairs rise towards +z, see zmTurned.
+	// Defaults to the pieces in zmpieces.go
+	floor      m.Object
+	wall       m.Object
+	closedDoor m.Object
+	openDoor   m.Object
+	platform   m.Object
+	hatch      m.Object
+	stairs     m.Object
+	// corners join the walls around them, picked by the number of walls
+	// and turned to meet them, see cornerPiece. A freestanding pillar,
+	// the end of a wall running towards +x, a straight wall along the
+	// x-axis, an L towards +x and +z, a T towards -x, +x and +z, and a cross
+	corner         m.Object
+	cornerEnd      m.Object
+	cornerStraight m.Object
+	cornerL        m.Object
+	cornerT        m.Object
+	cornerCross    m.Object
+	// material of the tiles and the default pieces, defaults to grey
 	material m.Material
+	// defaults to the core tile set if empty
+	tileSet zmTileSet
+	// number of copies of tiles by name, overriding those of the tile set
+	poolCounts map[string]int
+	// number of tiles along the x and z axes, defaults to 4x4
+	boardWidth  int
+	boardLength int
+	// size of a tile, defaults to 300 (~1')
+	tileSize float32
+	// seed for the layout; a board is the same every time for the same seed.
+	// Defaults to the current time
+	seed int64
+	// layout file to build the board from instead, see ExportZoneMortalis.
+	// The size of the board is read from the file
+	layoutFile string
+}
+
+// zmPlacement is a tile on the board, by index in the tile set,
+// rotated around its center by a number of quarter turns
+type zmPlacement struct {
+	tile     int
+	rotation int
+}
+
+// zmLayout holds the placements of all tiles on the board,
+// row by row along the x-axis
+type zmLayout struct {
+	width, length int
+	placements    []zmPlacement
 }
 
-func NewZoneMortalis(p ZoneMortalisParameters) m.Object {
-	alpha := newTile(zm_alpha, p)
-	beta := newTile(zm_beta, p)
-	gamma := newTile(zm_gamma, p)
-	delta := newTile(zm_delta, p)
-	epsilon := newTile(zm_epsilon, p)
-	zeta := newTile(zm_zeta, p)
-	eta := newTile(zm_eta, p)
-	theta := newTile(zm_theta, p)
+func (p ZoneMortalisParameters) withDefaults() ZoneMortalisParameters {
+	if len(p.tileSet.tiles) == 0 {
+		p.tileSet = defaultTileSet()
+	}
+	if p.boardWidth == 0 {
+		p.boardWidth = 4
+	}
+	if p.boardLength == 0 {
+		p.boardLength = 4
+	}
+	if p.tileSize == 0 {
+		p.tileSize = 300
+	}
+	if p.seed == 0 {
+		p.seed = time.Now().UnixNano()
+	}
+	if p.material == nil {
+		p.material = m.NewDiffuseMaterial(m.ConstantTexture{Color: m.NewColor(120, 120, 120)})
+	}
+	square := p.tileSize / float32(p.tileSet.squaresPerTile)
+	if p.floor == nil {
+		p.floor = zmFloor(p.material, square)
+	}
+	if p.wall == nil {
+		p.wall = zmWall(p.material, square)
+	}
+	if p.corner == nil {
+		p.corner = zmCorner(p.material, square, 0)
+	}
+	if p.cornerEnd == nil {
+		p.cornerEnd = zmCorner(p.material, square, zmRight)
+	}
+	if p.cornerStraight == nil {
+		p.cornerStraight = zmCorner(p.material, square, zmRight|zmLeft)
+	}
+	if p.cornerL == nil {
+		p.cornerL = zmCorner(p.material, square, zmRight|zmFar)
+	}
+	if p.cornerT == nil {
+		p.cornerT = zmCorner(p.material, square, zmRight|zmFar|zmLeft)
+	}
+	if p.cornerCross == nil {
+		p.cornerCross = zmCorner(p.material, square, zmRight|zmFar|zmLeft|zmNear)
+	}
+	if p.closedDoor == nil {
+		p.closedDoor = zmDoor(p.material, square, false)
+	}
+	if p.openDoor == nil {
+		p.openDoor = zmDoor(p.material, square, true)
+	}
+	if p.platform == nil {
+		p.platform = zmPlatform(p.material, square)
+	}
+	if p.hatch == nil {
+		p.hatch = zmHatch(p.material, square)
+	}
+	if p.stairs == nil {
+		p.stairs = zmStairs(p.material, square)
+	}
+	return p
+}
+
+// pool returns the tile index of each copy of a tile available
+func (p ZoneMortalisParameters) pool() []int {
+	pool := []int{}
+	for i, tile := range p.tileSet.tiles {
+		count := p.tileSet.counts[i]
+		if n, ok := p.poolCounts[tile.name]; ok {
+			count = n
+		}
+		for j := 0; j < count; j++ {
+			pool = append(pool, i)
+		}
+	}
+	return pool
+}
+
+func NewZoneMortalis(p ZoneMortalisParameters) (m.Object, error) {
+	p = p.withDefaults()
+	layout, err := p.layout()
+	if err != nil {
+		return nil, err
+	}
+	return buildBoard(layout, p), nil
+}
 
-	board := []m.Object{
-		alpha, alpha, beta, beta, gamma, gamma, delta, delta,
-		epsilon, epsilon, zeta, zeta, eta, eta, theta, theta,
+// layout reads the layout file if there is one, or solves a new layout
+func (p ZoneMortalisParameters) layout() (zmLayout, error) {
+	if p.layoutFile != "" {
+		return LoadLayout(p.layoutFile, p.tileSet)
 	}
+	return solveLayout(p)
+}
+
+// buildBoard places the tiles of layout on the board. Each piece gets its own
+// instance with the transform from its square all the way to the board:
+// instances nested inside instances report hits in the wrong frame.
+func buildBoard(layout zmLayout, p ZoneMortalisParameters) m.Object {
+	tiles := make([][]zmPiece, len(p.tileSet.tiles))
+	for i, tile := range p.tileSet.tiles {
+		tiles[i] = newTile(tile.squares, p)
+	}
+
+	objects := []m.Object{}
+	half := p.tileSize / 2.0
+	for i, placement := range layout.placements {
+		x, z := i%layout.width, i/layout.width
+		transform := m.Translate(m.Vector{float32(x) * p.tileSize, 0, float32(z) * p.tileSize})
+		if n := placement.rotation; n != 0 {
+			transform = transform.Mul(m.Translate(m.Vector{half, 0, half}))
+			transform = transform.Mul(m.RotateY(float64(n) * math.Pi / 2.0).Mul(m.Translate(m.Vector{-half, 0, -half})))
+		}
+		for _, piece := range tiles[placement.tile] {
+			objects = append(objects, m.NewSharedObject(piece.object, transform.Mul(piece.transform)))
+		}
+	}
+	return m.NewComplexObject(objects)
+}
+
+// newTile returns the pieces of a tile in the frame of the tile, including
+// the plate they stand on
+func newTile(squaredef [][]rune, p ZoneMortalisParameters) []zmPiece {
+	size := p.tileSize
+	n := len(squaredef)
+	square := size / float32(n)
+	// the tile itself is a thin plate under the squares
+	thickness := square / 50.0
+	tile := m.NewTriangleComplexObject(cuboid(p.material, m.Vector{0, 0, 0}, m.Vector{size, thickness, size}))
 
-	r := rand.New(rand.NewSource(time.Now().Unix()))
-	tiles := make([]m.Object, 16)
-	perm := r.Perm(16)
-	for i, randIndex := range perm {
-		tiles[i] = board[randIndex]
+	pieces := tilePieces(squaredef, p)
+	for i, piece := range pieces {
+		pieces[i].transform = m.Translate(m.Vector{0, thickness, 0}).Mul(piece.transform)
 	}
+	return append(pieces, zmPiece{tile, m.ScaleUniform(1.0)})
+}
 
-	i := 0
-	for z := 0; z < 4; z++ {
-		for x := 0; x < 4; x++ {
-			transform := m.Translate(m.Vector{float32(x * 300), 0, float32(z * 300)})
-			if n := r.Intn(4); n != 0 {
-				transform = transform.Mul(m.Translate(m.Vector{150, 0, 150}))
-				transform = transform.Mul(m.RotateY(float64(n) * math.Pi / 2.0).Mul(m.Translate(m.Vector{-150, 0, -150})))
+// zmPiece is a piece placed on a square of a tile, with its transform
+// from the frame of the square to the frame of the tile
+type zmPiece struct {
+	object    m.Object
+	transform m.Transform
+}
+
+// tilePieces returns the pieces on each square of a tile, the first row
+// at the far edge of the tile, standing on the plane y=0
+func tilePieces(squares [][]rune, p ZoneMortalisParameters) []zmPiece {
+	n := len(squares)
+	square := p.tileSize / float32(n)
+	pieces := []zmPiece{}
+	for z, row := range squares {
+		for x, r := range row {
+			frame := func(quarterTurns int) m.Transform {
+				return squareFrame(x, n-1-z, square, quarterTurns)
+			}
+			if walkable(r) && r != '=' && r != '!' {
+				pieces = append(pieces, zmPiece{p.floor, frame(0)})
+			}
+			if r == 'x' {
+				corner, quarterTurns := p.cornerPiece(squares, x, z)
+				pieces = append(pieces, zmPiece{corner, frame(quarterTurns)})
+				continue
 			}
-			tile := tiles[i]
-			tiles[i] = m.NewSharedObject(tile, transform)
-			i++
-		}
-	}
-	return m.NewComplexObject(tiles)
-}
-
-func newTile(squaredef [6][6]rune, p ZoneMortalisParameters) m.Object {
-	// extrude a tile
-	t := []m.Vector{{0, 0, 300}, {300, 0, 300}, {300, 0, 0}, {0, 0, 0}}
-	t1 := m.NewTriangle(t[0], t[1], t[2], p.material)
-	t2 := m.NewTriangle(t[0], t[2], t[3], p.material)
-	front := []m.Triangle{t1, t2}
-
-	ef := gen.ExtrusionFace{
-		Front:    front,
-		Outer:    [][]m.Vector{t},
-		Material: p.material,
-	}
-	tile := ef.Extrude(m.Vector{0, 1, 0})
-
-	// add squares
-	squares := []m.Object{}
-	for z := 0; z < 6; z++ {
-		for x := 0; x < 6; x++ {
-			switch squaredef[z][x] {
-			case 'x':
-				transform := m.Translate(m.Vector{float32(50 * x), 1, 250.0 - float32(50*z)})
-				c := m.NewSharedObject(p.corner, transform)
-				squares = append(squares, c)
-			case '-':
-				transform := m.Translate(m.Vector{float32(50 * x), 1, 250.0 - float32(50*z)})
-				//transform = transform.Mul(m.Translate(m.Vector{25, 0, 25}))
-				//transform = transform.Mul(m.RotateY(math.Pi / 2.0).Mul(m.Translate(m.Vector{-25, 0, -25})))
-				w := m.NewSharedObject(p.wall, transform)
-				squares = append(squares, w)
-			case '|':
-				transform := m.Translate(m.Vector{float32(50 * x), 1, 250.0 - float32(50*z)})
-				transform = transform.Mul(m.Translate(m.Vector{25, 0, 25}))
-				transform = transform.Mul(m.RotateY(math.Pi / 2.0).Mul(m.Translate(m.Vector{-25, 0, -25})))
-				w := m.NewSharedObject(p.wall, transform)
-				squares = append(squares, w)
-			case ' ':
-				transform := m.Translate(m.Vector{0.5 + float32(50*x), 1, 0.5 + 250.0 - float32(50*z)})
-				s := m.NewSharedObject(p.floor, transform)
-				squares = append(squares, s)
+			if o, ok := zmOrientations[r]; ok {
+				pieces = append(pieces, zmPiece{o.piece(p), frame(o.quarterTurns)})
 			}
 		}
 	}
+	return pieces
+}
+
+// squareFrame returns the transform from the frame of a square to the frame
+// of its tile: pieces in the frame of a square span from the origin to
+// (square, square) and are turned around the center of the square.
+// Squares are counted from the near left corner of the tile.
+func squareFrame(x, z int, square float32, quarterTurns int) m.Transform {
+	transform := m.Translate(m.Vector{square * float32(x), 0, square * float32(z)})
+	if quarterTurns%4 == 0 {
+		return transform
+	}
+	half := square / 2.0
+	transform = transform.Mul(m.Translate(m.Vector{half, 0, half}))
+	return transform.Mul(m.RotateY(float64(quarterTurns) * math.Pi / 2.0).Mul(m.Translate(m.Vector{-half, 0, -half})))
+}
+
+// zmOrientation is the piece for a glyph and the quarter turns it takes
+// from the way that piece is modelled
+type zmOrientation struct {
+	piece        func(ZoneMortalisParameters) m.Object
+	quarterTurns int
+}
+
+// zmOrientations holds the pieces for all glyphs but corners and empty squares.
+// A quarter turn takes +x to +z, which turns a wall along the x-axis into
+// one along the z-axis and stairs rising towards +z into stairs rising towards -x
+var zmOrientations = map[rune]zmOrientation{
+	'-': {func(p ZoneMortalisParameters) m.Object { return p.wall }, 0},
+	'|': {func(p ZoneMortalisParameters) m.Object { return p.wall }, 1},
+	'=': {func(p ZoneMortalisParameters) m.Object { return p.closedDoor }, 0},
+	'!': {func(p ZoneMortalisParameters) m.Object { return p.closedDoor }, 1},
+	'_': {func(p ZoneMortalisParameters) m.Object { return p.openDoor }, 0},
+	':': {func(p ZoneMortalisParameters) m.Object { return p.openDoor }, 1},
+	'#': {func(p ZoneMortalisParameters) m.Object { return p.platform }, 0},
+	'o': {func(p ZoneMortalisParameters) m.Object { return p.hatch }, 0},
+	'^': {func(p ZoneMortalisParameters) m.Object { return p.stairs }, 0},
+	'<': {func(p ZoneMortalisParameters) m.Object { return p.stairs }, 1},
+	'v': {func(p ZoneMortalisParameters) m.Object { return p.stairs }, 2},
+	'>': {func(p ZoneMortalisParameters) m.Object { return p.stairs }, 3},
+}
 
-	return m.NewComplexObject(append(squares, tile))
+// cornerPiece returns the corner piece joining the walls next to the corner
+// at square x of row z, and the quarter turns that take it to those walls.
+// Only walls on the tile count: corners do not reach across its edges.
+func (p ZoneMortalisParameters) cornerPiece(squares [][]rune, x, z int) (m.Object, int) {
+	n := len(squares)
+	arms := 0
+	for _, nb := range []struct {
+		dx, dz int
+		arm    int
+		along  func(rune) bool
+	}{
+		{1, 0, zmRight, alongX},
+		{0, -1, zmFar, alongZ},
+		{-1, 0, zmLeft, alongX},
+		{0, 1, zmNear, alongZ},
+	} {
+		nx, nz := x+nb.dx, z+nb.dz
+		if nx < 0 || nx >= n || nz < 0 || nz >= n {
+			continue
+		}
+		if q := squares[nz][nx]; nb.along(q) || q == 'x' {
+			arms |= nb.arm
+		}
+	}
+	for _, c := range []struct {
+		piece m.Object
+		arms  int
+	}{
+		{p.corner, 0},
+		{p.cornerEnd, zmRight},
+		{p.cornerStraight, zmRight | zmLeft},
+		{p.cornerL, zmRight | zmFar},
+		{p.cornerT, zmRight | zmFar | zmLeft},
+		{p.cornerCross, zmRight | zmFar | zmLeft | zmNear},
+	} {
+		for quarterTurns := 0; quarterTurns < 4; quarterTurns++ {
+			if turnArms(c.arms, quarterTurns) == arms {
+				return c.piece, quarterTurns
+			}
+		}
+	}
+	panic("unreachable")
 }
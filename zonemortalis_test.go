@@ -0,0 +1,670 @@
+package main
+
+import (
+	"bufio"
+	"image/png"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	m "github.com/deosjr/GRayT/src/model"
+	"github.com/deosjr/GRayT/src/render"
+)
+
+func TestReadTile(t *testing.T) {
+	for i, tt := range []struct {
+		tile    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			tile: " x--x-\n |\n",
+			want: []string{" x--x-", " |    ", "      ", "      ", "      ", "      "},
+		},
+		{
+			// leading empty rows count, trailing ones may be left out
+			tile: "\n\n    x|\n",
+			want: []string{"      ", "      ", "    x|", "      ", "      ", "      "},
+		},
+		{
+			tile:    " x--x--\n",
+			wantErr: true,
+		},
+		{
+			tile:    " x-?\n",
+			wantErr: true,
+		},
+		{
+			tile:    "x\nx\nx\nx\nx\nx\nx\n",
+			wantErr: true,
+		},
+		{
+			// doors in walls along both axes, stairs up to a platform
+			tile: " x=x-_\n :\n |   #\n !   ^\n x\n",
+			want: []string{" x=x-_", " :    ", " |   #", " !   ^", " x    ", "      "},
+		},
+		{
+			tile:    "  = \n",
+			wantErr: true,
+		},
+		{
+			tile:    " -\n !\n",
+			wantErr: true,
+		},
+		{
+			tile:    "\n >\n",
+			wantErr: true,
+		},
+		{
+			// a platform with a hatch, reached by stairs onto the hatch
+			tile: "\n #o\n  ^\n\no\n",
+			want: []string{"      ", " #o   ", "  ^   ", "      ", "o     ", "      "},
+		},
+		{
+			tile:    "\n o\n",
+			wantErr: true,
+		},
+	} {
+		got, err := readTile(bufio.NewScanner(strings.NewReader(tt.tile)), 6)
+		if err != nil {
+			if !tt.wantErr {
+				t.Errorf("%d): unexpected error: %s", i, err.Error())
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Errorf("%d): expected error", i)
+			continue
+		}
+		rows := make([]string, len(got))
+		for z, row := range got {
+			rows[z] = string(row)
+		}
+		if !reflect.DeepEqual(rows, tt.want) {
+			t.Errorf("%d): got %q want %q", i, rows, tt.want)
+		}
+	}
+}
+
+func TestLoadTileSet(t *testing.T) {
+	fsys := fstest.MapFS{
+		"set/tileset.txt": {Data: []byte("# test set\nsquares 2\ntile a.txt 3\ntile b.txt 1\n")},
+		"set/a.txt":       {Data: []byte("x-\n|\n")},
+		"set/b.txt":       {Data: []byte("")},
+	}
+	got, err := loadTileSet(fsys, "set/tileset.txt")
+	if err != nil {
+		t.Fatalf("error in load: %s", err.Error())
+	}
+	want := zmTileSet{
+		squaresPerTile: 2,
+		tiles: []zmTile{
+			{name: "a", squares: [][]rune{[]rune("x-"), []rune("| ")}},
+			{name: "b", squares: [][]rune{[]rune("  "), []rune("  ")}},
+		},
+		counts: []int{3, 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+
+	core := defaultTileSet()
+	if len(core.tiles) != 8 || core.squaresPerTile != 6 {
+		t.Errorf("expected 8 core tiles of 6 squares, got %d of %d", len(core.tiles), core.squaresPerTile)
+	}
+	if got := string(core.tiles[0].squares[1]); core.tiles[0].name != "alpha" || got != " x--x-" {
+		t.Errorf("unexpected first core tile %s, row 1: %q", core.tiles[0].name, got)
+	}
+}
+
+func TestBuildBoard(t *testing.T) {
+	p := testBoardParams()
+	for i, tt := range []struct {
+		layout  zmLayout
+		wantMax m.Vector
+	}{
+		{
+			layout:  zmLayout{width: 2, length: 2, placements: []zmPlacement{{0, 0}, {0, 1}, {0, 2}, {0, 3}}},
+			wantMax: m.Vector{200, 101, 200},
+		},
+		{
+			layout:  zmLayout{width: 6, length: 4, placements: append([]zmPlacement{{0, 1}}, make([]zmPlacement, 23)...)},
+			wantMax: m.Vector{600, 101, 400},
+		},
+	} {
+		b := buildBoard(tt.layout, p).Bound(m.ScaleUniform(1.0))
+		if !compareVector(b.Pmin, m.Vector{}) || !compareVector(b.Pmax, tt.wantMax) {
+			t.Errorf("%d): got bounds %v %v want %v %v", i, b.Pmin, b.Pmax, m.Vector{}, tt.wantMax)
+		}
+	}
+}
+
+func TestBuildBoardHits(t *testing.T) {
+	p := testBoardParams()
+	// tile a, then tile c with a platform on its far left square
+	board := buildBoard(zmLayout{width: 2, length: 1, placements: []zmPlacement{{0, 0}, {2, 0}}}, p)
+	for i, tt := range []struct {
+		from, direction m.Vector
+		point, normal   m.Vector
+	}{
+		// onto the platform, on a plate 1 thick
+		{m.Vector{125, 500, 75}, m.Vector{0, -1, 0}, m.Vector{125, 76, 75}, m.Vector{0, 1, 0}},
+		// over the floor of tile a into the wall on its far right square
+		{m.Vector{75, 50, -500}, m.Vector{0, 0, 1}, m.Vector{75, 50, 50}, m.Vector{0, 0, -1}},
+		// onto the floor of tile c, next to the platform
+		{m.Vector{175, 500, 75}, m.Vector{0, -1, 0}, m.Vector{175, 2, 75}, m.Vector{0, 1, 0}},
+	} {
+		si, hit := board.Intersect(m.NewRay(tt.from, tt.direction))
+		if !hit {
+			t.Errorf("%d): expected a hit", i)
+			continue
+		}
+		if !compareVector(si.Point, tt.point) || !compareVector(si.GetNormal(), tt.normal) {
+			t.Errorf("%d): got hit at %v normal %v want %v normal %v", i, si.Point, si.GetNormal(), tt.point, tt.normal)
+		}
+	}
+}
+
+func TestRenderBoard(t *testing.T) {
+	p := ZoneMortalisParameters{tileSize: 3, seed: 1}.withDefaults()
+	n := len(p.tileSet.tiles)
+	layout := zmLayout{width: 2, length: 2, placements: []zmPlacement{{0, 0}, {1 % n, 1}, {2 % n, 2}, {3 % n, 3}}}
+	board := buildBoard(layout, p)
+
+	// looking straight down with the light at the camera, everything the
+	// camera sees is lit, apart from faces seen edge-on
+	w, h := 40, 40
+	camera := m.NewPerspectiveCamera(uint(w), uint(h), 0.25*math.Pi)
+	scene := m.NewScene(camera)
+	from := m.Vector{3, 10, 3}
+	scene.AddLights(m.NewPointLight(from, m.NewColor(255, 255, 255), 3000))
+	scene.Add(board)
+	scene.Precompute()
+	camera.LookAt(from, m.Vector{3, 0, 3}, ez)
+	film := render.Render(render.Params{Scene: scene, NumWorkers: 4, NumSamples: 1, TracerType: m.WhittedStyle})
+
+	// Film only hands out its pixels as an image file
+	name := filepath.Join(t.TempDir(), "board.png")
+	film.SaveAsPNG(name)
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen, lit := 0, 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			_, hit := board.Intersect(camera.PixelRay(float32(x)+0.5, float32(y)+0.5))
+			if !hit {
+				if r+g+b != 0 {
+					t.Errorf("pixel %d,%d misses the board but is not black", x, y)
+				}
+				continue
+			}
+			seen++
+			if r+g+b != 0 {
+				lit++
+			}
+		}
+	}
+	if seen < w*h/2 {
+		t.Errorf("expected the board to fill most of the image, got %d of %d pixels", seen, w*h)
+	}
+	if lit < seen*4/5 {
+		t.Errorf("expected the board to be lit, got %d of %d pixels", lit, seen)
+	}
+}
+
+func TestTilePieces(t *testing.T) {
+	mat := &m.DiffuseMaterial{}
+	box := func(x0, z0, x1, z1, h float32) m.Object {
+		return m.NewTriangleComplexObject(cuboid(mat, m.Vector{x0, 0, z0}, m.Vector{x1, h, z1}))
+	}
+	p := ZoneMortalisParameters{
+		floor:    box(0, 0, 50, 50, 1),
+		wall:     box(0, 0, 50, 10, 100),
+		openDoor: box(0, 0, 50, 20, 100),
+		platform: box(0, 0, 50, 50, 75),
+		stairs:   box(0, 40, 50, 50, 75),
+		// corners reach out from the center of the square
+		cornerEnd: box(25, 20, 50, 30, 100),
+		cornerL:   box(20, 20, 50, 50, 100),
+		material:  mat,
+		tileSet:   zmTileSet{squaresPerTile: 3},
+		tileSize:  150,
+		seed:      1,
+	}.withDefaults()
+	squares := [][]rune{[]rune("x-x"), []rune("|#:"), []rune("x^|")}
+	pieces := tilePieces(squares, p)
+	if len(pieces) != 12 {
+		t.Fatalf("expected 12 pieces, got %d", len(pieces))
+	}
+	for i, want := range []struct {
+		piece      m.Object
+		pmin, pmax m.Vector
+	}{
+		// an L reaching right and near, a wall along the x-axis, an L reaching left and near
+		{p.cornerL, m.Vector{20, 0, 100}, m.Vector{50, 100, 130}},
+		{p.wall, m.Vector{50, 0, 100}, m.Vector{100, 100, 110}},
+		{p.cornerL, m.Vector{100, 0, 100}, m.Vector{130, 100, 130}},
+		// walls and doors along the z-axis are turned to the right of their square
+		{p.wall, m.Vector{40, 0, 50}, m.Vector{50, 100, 100}},
+		{p.floor, m.Vector{50, 0, 50}, m.Vector{100, 1, 100}},
+		{p.platform, m.Vector{50, 0, 50}, m.Vector{100, 75, 100}},
+		{p.floor, m.Vector{100, 0, 50}, m.Vector{150, 1, 100}},
+		{p.openDoor, m.Vector{130, 0, 50}, m.Vector{150, 100, 100}},
+		// the end of a wall reaching far, stairs rising towards the platform
+		{p.cornerEnd, m.Vector{20, 0, 25}, m.Vector{30, 100, 50}},
+		{p.floor, m.Vector{50, 0, 0}, m.Vector{100, 1, 50}},
+		{p.stairs, m.Vector{50, 0, 40}, m.Vector{100, 75, 50}},
+		{p.wall, m.Vector{140, 0, 0}, m.Vector{150, 100, 50}},
+	} {
+		got := pieces[i]
+		if got.object != want.piece {
+			t.Errorf("%d): unexpected piece", i)
+		}
+		b := m.NewSharedObject(got.object, got.transform).Bound(m.ScaleUniform(1.0))
+		if !compareVector(b.Pmin, want.pmin) || !compareVector(b.Pmax, want.pmax) {
+			t.Errorf("%d): got bounds %v %v want %v %v", i, b.Pmin, b.Pmax, want.pmin, want.pmax)
+		}
+	}
+}
+
+func TestCornerPiece(t *testing.T) {
+	p := ZoneMortalisParameters{tileSet: zmTileSet{squaresPerTile: 3}, tileSize: 150, seed: 1}.withDefaults()
+	for i, tt := range []struct {
+		squares      []string
+		want         m.Object
+		quarterTurns int
+	}{
+		{squares: []string{"   ", " x ", "   "}, want: p.corner},
+		{squares: []string{"   ", " x-", "   "}, want: p.cornerEnd},
+		{squares: []string{"   ", " x ", " | "}, want: p.cornerEnd, quarterTurns: 3},
+		{squares: []string{"   ", "_x=", "   "}, want: p.cornerStraight},
+		{squares: []string{" ! ", " x ", " : "}, want: p.cornerStraight, quarterTurns: 1},
+		{squares: []string{" | ", "-x ", "   "}, want: p.cornerL, quarterTurns: 1},
+		{squares: []string{" | ", "-x ", " x "}, want: p.cornerT, quarterTurns: 1},
+		{squares: []string{" x ", "-x-", " | "}, want: p.cornerCross},
+		// walls that do not run towards the corner do not join it
+		{squares: []string{" - ", "|x|", " - "}, want: p.corner},
+	} {
+		squares := make([][]rune, len(tt.squares))
+		for z, row := range tt.squares {
+			squares[z] = []rune(row)
+		}
+		got, quarterTurns := p.cornerPiece(squares, 1, 1)
+		if got != tt.want || quarterTurns != tt.quarterTurns {
+			t.Errorf("%d): got piece %p turned %d want %p turned %d", i, got, quarterTurns, tt.want, tt.quarterTurns)
+		}
+	}
+
+	// nor do walls beyond the edge of the tile
+	squares := [][]rune{[]rune("x--"), []rune("|  "), []rune("   ")}
+	if got, quarterTurns := p.cornerPiece(squares, 0, 0); got != p.cornerL || quarterTurns != 3 {
+		t.Errorf("corner at the edge: got piece %p turned %d want %p turned 3", got, quarterTurns, p.cornerL)
+	}
+}
+
+func TestRotateSquares(t *testing.T) {
+	// a rotated tile on the board should have its walls and stairs
+	// where the squares of that tile say they are after rotateSquares
+	p := testBoardParams()
+	for _, tile := range []int{0, 2} {
+		squares := p.tileSet.tiles[tile].squares
+		for n := 0; n < 4; n++ {
+			rotated := buildBoard(zmLayout{width: 1, length: 1, placements: []zmPlacement{{tile, n}}}, p)
+			q := p
+			q.tileSet = zmTileSet{squaresPerTile: 2, tiles: []zmTile{{name: "rotated", squares: squares}}, counts: []int{1}}
+			want := buildBoard(zmLayout{width: 1, length: 1, placements: []zmPlacement{{}}}, q)
+			for _, x := range []float32{10, 40, 60, 90} {
+				for _, z := range []float32{10, 40, 60, 90} {
+					ray := m.NewRay(m.Vector{x, 500, z}, m.Vector{0, -1, 0})
+					got, _ := rotated.Intersect(ray)
+					wantSi, _ := want.Intersect(ray)
+					if !approx(got.Point.Y, wantSi.Point.Y) {
+						t.Errorf("%d %d): square at %f %f has height %f want %f", tile, n, x, z, got.Point.Y, wantSi.Point.Y)
+					}
+				}
+			}
+			squares = rotateSquares(squares)
+		}
+	}
+}
+
+func TestSolveLayout(t *testing.T) {
+	for i, tt := range []struct {
+		width, length int
+		seed          int64
+		poolCounts    map[string]int
+		// whether all walls should continue across seams;
+		// the core set cannot do that on a full board
+		continuous bool
+		wantErr    bool
+	}{
+		{width: 4, length: 4, seed: 1},
+		{width: 4, length: 4, seed: 42},
+		{width: 3, length: 2, seed: 7},
+		{width: 6, length: 4, seed: 1, poolCounts: map[string]int{"theta": 10}},
+		{width: 4, length: 4, seed: 1, poolCounts: map[string]int{"gamma": 0, "delta": 0, "theta": 6}, continuous: true},
+		// large boards take long if every change counts the whole board again
+		{width: 8, length: 8, seed: 1, poolCounts: map[string]int{"alpha": 10, "beta": 10, "gamma": 10, "delta": 10, "epsilon": 10, "zeta": 10, "eta": 10, "theta": 10}},
+		{width: 6, length: 4, seed: 1, wantErr: true},
+		// the room in the corner of alpha is sealed off by the edge of the board
+		{width: 1, length: 1, seed: 1, poolCounts: map[string]int{"beta": 0, "gamma": 0, "delta": 0, "epsilon": 0, "zeta": 0, "eta": 0, "theta": 0}, wantErr: true},
+	} {
+		p := ZoneMortalisParameters{
+			boardWidth:  tt.width,
+			boardLength: tt.length,
+			poolCounts:  tt.poolCounts,
+			seed:        tt.seed,
+		}.withDefaults()
+		layout, err := solveLayout(p)
+		if err != nil {
+			if !tt.wantErr {
+				t.Errorf("%d): unexpected error: %s", i, err.Error())
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Errorf("%d): expected error", i)
+			continue
+		}
+		again, _ := solveLayout(p)
+		if !reflect.DeepEqual(layout, again) {
+			t.Errorf("%d): same seed gave different layouts", i)
+		}
+		// replay the layout and check walls and floor on the whole board
+		n := p.tileSet.squaresPerTile
+		s := &zmSolver{n: n, width: tt.width, length: tt.length, grid: make([][]rune, tt.length*n)}
+		for z := range s.grid {
+			s.grid[z] = make([]rune, tt.width*n)
+		}
+		used := map[int]int{}
+		for j, pl := range layout.placements {
+			squares := p.tileSet.tiles[pl.tile].squares
+			for k := 0; k < pl.rotation; k++ {
+				squares = rotateSquares(squares)
+			}
+			s.place(j%tt.width, j/tt.width, squares)
+			used[pl.tile]++
+		}
+		if tt.continuous {
+			for z, row := range s.grid {
+				for x, r := range row {
+					if x > 0 && x%n == 0 && !wallsMeet(row[x-1], r, alongX) {
+						t.Errorf("%d): wall dead-ends at seam next to square %d %d", i, x, z)
+					}
+					if z > 0 && z%n == 0 && !wallsMeet(s.grid[z-1][x], r, alongZ) {
+						t.Errorf("%d): wall dead-ends at seam next to square %d %d", i, x, z)
+					}
+				}
+			}
+		}
+		if !s.floorConnected() {
+			t.Errorf("%d): floor is not connected", i)
+		}
+		pool := map[int]int{}
+		for _, tile := range p.pool() {
+			pool[tile]++
+		}
+		for tile, n := range used {
+			if n > pool[tile] {
+				t.Errorf("%d): used tile %d %d times, only %d in pool", i, tile, n, pool[tile])
+			}
+		}
+	}
+}
+
+func TestSolveLayoutPool(t *testing.T) {
+	// a small board leaves many tiles in the pool to exchange
+	for seed := int64(1); seed <= 200; seed++ {
+		p := ZoneMortalisParameters{boardWidth: 3, boardLength: 2, seed: seed}.withDefaults()
+		layout, err := solveLayout(p)
+		if err != nil {
+			t.Fatalf("seed %d: %s", seed, err.Error())
+		}
+		pool := map[int]int{}
+		for _, tile := range p.pool() {
+			pool[tile]++
+		}
+		for _, pl := range layout.placements {
+			pool[pl.tile]--
+			if pool[pl.tile] < 0 {
+				t.Fatalf("seed %d: used tile %d more often than the pool holds", seed, pl.tile)
+			}
+		}
+	}
+}
+
+func TestFloorConnected(t *testing.T) {
+	for i, tt := range []struct {
+		// '.' is a square where no tile has been placed yet
+		rows []string
+		want bool
+	}{
+		{rows: []string{"  ", "  "}, want: true},
+		{rows: []string{"  ", "-x"}, want: true},
+		{rows: []string{" | ", "-x-"}, want: false},
+		// platforms are reached by stairs or a hatch, not from the floor
+		{rows: []string{" #", "  "}, want: false},
+		{rows: []string{"^#", "  "}, want: true},
+		{rows: []string{" o#", "   "}, want: true},
+		{rows: []string{" #", ".."}, want: true},
+	} {
+		s := &zmSolver{grid: make([][]rune, len(tt.rows))}
+		for z, row := range tt.rows {
+			s.grid[z] = []rune(strings.ReplaceAll(row, ".", "\x00"))
+		}
+		if got := s.floorConnected(); got != tt.want {
+			t.Errorf("%d): got %t want %t", i, got, tt.want)
+		}
+	}
+}
+
+func TestSolverDeadEndsAround(t *testing.T) {
+	p := ZoneMortalisParameters{boardWidth: 4, boardLength: 3, seed: 1}.withDefaults()
+	s := newSolver(p)
+	if !s.solve(0) {
+		t.Fatal("no layout")
+	}
+	// changing spots i and j changes the dead ends around them
+	// as much as those on the whole board
+	r := rand.New(rand.NewSource(1))
+	for k := 0; k < 1000; k++ {
+		i, j := r.Intn(len(s.spots)), r.Intn(len(s.spots))
+		cost, before := s.deadEnds(), s.deadEndsAround(i, j)
+		s.spots[i] = r.Intn(len(s.candidates))
+		s.spots[j] = r.Intn(len(s.candidates))
+		if got, want := s.deadEndsAround(i, j)-before, s.deadEnds()-cost; got != want {
+			t.Fatalf("spots %d and %d: dead ends around changed by %d, on the board by %d", i, j, got, want)
+		}
+	}
+}
+
+func TestReadLayout(t *testing.T) {
+	tileSet := testBoardParams().tileSet
+	for i, tt := range []struct {
+		layout  string
+		want    zmLayout
+		wantErr bool
+	}{
+		{
+			layout: "# test\nboard 2 1\ntile 1 0 a 3\ntile 0 0 b 0\n",
+			want:   zmLayout{width: 2, length: 1, placements: []zmPlacement{{1, 0}, {0, 3}}},
+		},
+		{
+			layout:  "board 2 1\ntile 0 0 b 0\n",
+			wantErr: true,
+		},
+		{
+			layout:  "board 1 1\ntile 0 0 d 0\n",
+			wantErr: true,
+		},
+		{
+			layout:  "board 1 1\ntile 0 0 a 4\n",
+			wantErr: true,
+		},
+		{
+			layout:  "board 1 1\ntile 0 0 a 0\ntile 0 0 b 0\n",
+			wantErr: true,
+		},
+		{
+			layout:  "tile 0 0 a 0\nboard 1 1\n",
+			wantErr: true,
+		},
+	} {
+		got, err := readLayout(strings.NewReader(tt.layout), tileSet)
+		if err != nil {
+			if !tt.wantErr {
+				t.Errorf("%d): unexpected error: %s", i, err.Error())
+			}
+			continue
+		}
+		if tt.wantErr {
+			t.Errorf("%d): expected error", i)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d): got %v want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestExportZoneMortalis(t *testing.T) {
+	p := ZoneMortalisParameters{seed: 3}.withDefaults()
+	basename := filepath.Join(t.TempDir(), "board")
+	if err := ExportZoneMortalis(p, basename); err != nil {
+		t.Fatalf("error in export: %s", err.Error())
+	}
+	want, _ := solveLayout(p)
+	p.layoutFile = basename + ".txt"
+	got, err := p.layout()
+	if err != nil {
+		t.Fatalf("error reading layout back: %s", err.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got layout %v want %v", got, want)
+	}
+	if _, err := NewZoneMortalis(p); err != nil {
+		t.Errorf("error building board from layout: %s", err.Error())
+	}
+	missing := p
+	missing.layoutFile = basename + ".missing"
+	if _, err := NewZoneMortalis(missing); err == nil {
+		t.Errorf("expected error for a missing layout file")
+	}
+	ascii, err := os.ReadFile(basename + ".map")
+	if err != nil {
+		t.Fatalf("error reading map: %s", err.Error())
+	}
+	rows := strings.Split(strings.TrimSuffix(string(ascii), "\n"), "\n")
+	if len(rows) != 24 || len(rows[0]) != 24 {
+		t.Errorf("expected a map of 24x24 squares, got %d rows of %d", len(rows), len(rows[0]))
+	}
+	if _, err := os.Stat(basename + ".svg"); err != nil {
+		t.Errorf("expected svg plan: %s", err.Error())
+	}
+
+	// tile a turned once, next to an empty tile, has its corner at the near left
+	layout := zmLayout{width: 2, length: 1, placements: []zmPlacement{{1, 0}, {0, 1}}}
+	var b strings.Builder
+	writeASCIIMap(&b, layout, testBoardParams().tileSet)
+	if got, want := b.String(), "..|.\n..x-\n"; got != want {
+		t.Errorf("got map %q want %q", got, want)
+	}
+
+	// tile names are text in the svg plan
+	tileSet := testBoardParams().tileSet
+	tileSet.tiles[1].name = "<b&>"
+	b.Reset()
+	writeSVGMap(&b, layout, tileSet, 10)
+	if svg := b.String(); strings.Contains(svg, "<b&>") || !strings.Contains(svg, "&lt;b&amp;&gt; 0</text>") {
+		t.Errorf("expected escaped tile name in svg plan, got %s", svg)
+	}
+}
+
+func TestDefaultPieces(t *testing.T) {
+	mat := &m.DiffuseMaterial{}
+	for i, tt := range []struct {
+		piece   m.Object
+		wantMin m.Vector
+		wantMax m.Vector
+		// a point above the top of the piece
+		top m.Vector
+	}{
+		// walls run along the x-axis, panels and pipes stay within the square
+		{piece: zmWall(mat, 50), wantMin: m.Vector{0, 0, 0}, wantMax: m.Vector{50, 75, 50}, top: m.Vector{25, 200, 25}},
+		{piece: zmCorner(mat, 50, 0), wantMin: m.Vector{7.5, 0, 7.5}, wantMax: m.Vector{42.5, 82.5, 42.5}, top: m.Vector{25, 200, 25}},
+		{piece: zmCorner(mat, 50, zmRight), wantMin: m.Vector{7.5, 0, 7.5}, wantMax: m.Vector{50, 82.5, 42.5}, top: m.Vector{45, 200, 25}},
+		{piece: zmCorner(mat, 50, zmFar|zmNear), wantMin: m.Vector{7.5, 0, 0}, wantMax: m.Vector{42.5, 82.5, 50}, top: m.Vector{25, 200, 45}},
+		{piece: zmFloor(mat, 50), wantMin: m.Vector{0.5, 0, 0.5}, wantMax: m.Vector{49.5, 2.5, 49.5}, top: m.Vector{2, 200, 2}},
+		{piece: zmDoor(mat, 50, false), wantMin: m.Vector{0, 0, 0}, wantMax: m.Vector{50, 75, 50}, top: m.Vector{25, 200, 25}},
+		{piece: zmDoor(mat, 50, true), wantMin: m.Vector{0, 0, 0}, wantMax: m.Vector{50, 75, 50}, top: m.Vector{25, 200, 25}},
+		{piece: zmPlatform(mat, 50), wantMin: m.Vector{0, 0, 0}, wantMax: m.Vector{50, 75, 50}, top: m.Vector{2, 200, 2}},
+		// the lid of a hatch stands open above the deck
+		{piece: zmHatch(mat, 50), wantMin: m.Vector{0, 0, 0}, wantMax: m.Vector{50, 95, 50}, top: m.Vector{2, 200, 2}},
+		{piece: zmStairs(mat, 50), wantMin: m.Vector{0, 0, 0}, wantMax: m.Vector{50, 75, 50}, top: m.Vector{25, 200, 25}},
+	} {
+		b := tt.piece.Bound(m.ScaleUniform(1.0))
+		for _, v := range []m.Vector{b.Pmin, b.Pmax} {
+			if v.X < tt.wantMin.X-1e-3 || v.Y < tt.wantMin.Y-1e-3 || v.Z < tt.wantMin.Z-1e-3 || v.X > tt.wantMax.X+1e-3 || v.Y > tt.wantMax.Y+1e-3 || v.Z > tt.wantMax.Z+1e-3 {
+				t.Errorf("%d): got bounds %v %v want within %v %v", i, b.Pmin, b.Pmax, tt.wantMin, tt.wantMax)
+				break
+			}
+		}
+		// pieces face outwards
+		ray := m.NewRay(tt.top, m.Vector{0, -1, 0})
+		if si, hit := tt.piece.Intersect(ray); !hit || si.GetNormal().Dot(ray.Direction) >= 0 {
+			t.Errorf("%d): ray from above should hit the top of the piece", i)
+		}
+	}
+}
+
+func TestHatchOpening(t *testing.T) {
+	hatch := zmHatch(&m.DiffuseMaterial{}, 50)
+	// straight down through the hatch, clear of the ladder
+	if _, hit := hatch.Intersect(m.NewRay(m.Vector{25, 200, 20}, m.Vector{0, -1, 0})); hit {
+		t.Error("ray down through the hatch should not hit the piece")
+	}
+	// the ladder reaches from the floor up to the deck
+	for _, y := range []float32{5, 70} {
+		if si, hit := hatch.Intersect(m.NewRay(m.Vector{18, y, 20}, m.Vector{0, 0, 1})); !hit || si.GetNormal().Z >= 0 {
+			t.Errorf("ray towards the far side of the hatch at height %v should hit the ladder", y)
+		}
+	}
+}
+
+func testBoardParams() ZoneMortalisParameters {
+	mat := &m.DiffuseMaterial{}
+	piece := func(size, h float32) m.Object {
+		return m.NewTriangleComplexObject(m.NewCuboid(m.NewAABB(m.Vector{0, 0, 0}, m.Vector{size, h, size}), mat).Tesselate())
+	}
+	return ZoneMortalisParameters{
+		floor:    piece(49, 1),
+		wall:     piece(50, 100),
+		corner:   piece(50, 100),
+		platform: piece(50, 75),
+		material: mat,
+		tileSet: zmTileSet{
+			squaresPerTile: 2,
+			tiles: []zmTile{
+				{name: "a", squares: [][]rune{[]rune("x-"), []rune("| ")}},
+				{name: "b", squares: [][]rune{[]rune("  "), []rune("  ")}},
+				{name: "c", squares: [][]rune{[]rune("# "), []rune("^ ")}},
+			},
+			counts: []int{3, 1, 1},
+		},
+		tileSize: 100,
+		seed:     1,
+	}.withDefaults()
+}